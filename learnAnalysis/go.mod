@@ -1,5 +1,10 @@
 module github.com/Heph789/personalGoExperiments/learnAnalysis
 
-go 1.16
+go 1.26.0
 
-require golang.org/x/tools v0.1.0
+require golang.org/x/tools v0.50.0
+
+require (
+	golang.org/x/mod v0.41.0 // indirect
+	golang.org/x/sync v0.23.0 // indirect
+)
@@ -5,10 +5,10 @@ import (
 
 	"github.com/Heph789/personalGoExperiments/learnAnalysis/sa"
 
-	"golang.org/x/tools/go/analysis/singlechecker"
+	"golang.org/x/tools/go/analysis/multichecker"
 )
 
 func main() {
 	fmt.Println("-----------------\n-----------------\n-----------------\n-----------------\n-----------------")
-	singlechecker.Main(sa.Analyzer)
+	multichecker.Main(sa.Analyzer, sa.OrderAnalyzer, sa.BlockingAnalyzer)
 }
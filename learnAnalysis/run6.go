@@ -61,10 +61,8 @@ func run6(pass *analysis.Pass) (interface{}, error) {
 				if keepTrackOf.foundRLock > 0 { // if we have already seen an RLock method without seeing a corresponding RUnlock
 					pass.Reportf(
 						node.Pos(),
-						fmt.Sprintf(
-							"%v",
-							errNestedRLock,
-						),
+						"%v",
+						errNestedRLock,
 					)
 				}
 				keepTrackOf.incFRU()
@@ -74,11 +72,9 @@ func run6(pass *analysis.Pass) (interface{}, error) {
 				if stack := hasNestedRLock(call, inspect, pass, make(map[string]bool)); stack != "" {
 					pass.Reportf(
 						node.Pos(),
-						fmt.Sprintf(
-							"%v\n%v",
-							errNestedRLock,
-							stack,
-						),
+						"%v\n%v",
+						errNestedRLock,
+						stack,
 					)
 				}
 			}
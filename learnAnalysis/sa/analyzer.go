@@ -14,22 +14,62 @@ import (
 	"go/types"
 
 	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/buildssa"
+	"golang.org/x/tools/go/analysis/passes/ctrlflow"
 	"golang.org/x/tools/go/analysis/passes/inspect"
-	"golang.org/x/tools/go/ast/astutil"
 	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/cfg"
 	"golang.org/x/tools/go/types/typeutil"
 )
 
 // Analyzer runs static analysis.
 var Analyzer = &analysis.Analyzer{
 	Name:     "experiment",
-	Doc:      "Checks for recursive or nested RLock calls",
-	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Doc:      "Checks for recursive or nested RLock and Lock calls, RLock to Lock upgrades, locks still held at return, unmatched unlock calls, try-locks of a held lock and waits for goroutines acquiring a held lock",
+	Requires: []*analysis.Analyzer{inspect.Analyzer, ctrlflow.Analyzer, buildssa.Analyzer, summariesAnalyzer},
 	Run:      run,
 }
 
+// engine picks how locks are identified and tracked: "ast" follows the selectors of lock calls, while "ssa" follows the
+// SSA values they are called on
+var engine string
+
+func init() {
+	Analyzer.Flags.StringVar(&engine, "engine", "ast", "lock analysis engine to use (ast or ssa)")
+	Analyzer.Flags.BoolVar(&mayAlias, "mayalias", false, "report locks selected with different indices from the same slice, array or map as the same lock")
+}
+
 var errNestedRLock = errors.New("found recursive read lock call")
 
+var errLockUpgrade = errors.New("found write lock call while holding read lock")
+
+var errNestedLock = errors.New("found lock call while holding write lock")
+
+var errLockLeak = errors.New("found lock still held at return")
+
+var errUnlockNotHeld = errors.New("found unlock of a lock that is not held")
+
+var errDeferredUnlockNotHeld = errors.New("found deferred unlock of a lock that is not held")
+
+var errWaitSpawned = errors.New("found wait for a goroutine acquiring a held lock")
+
+var errTryLockHeld = errors.New("found try lock call while holding the lock")
+
+// mismatchedUnlockErrors maps each unlock method to the error reported when it releases a lock acquired with the other
+// lock method
+var mismatchedUnlockErrors = map[string]error{
+	"RUnlock": errors.New("found read unlock of a write lock"),
+	"Unlock":  errors.New("found write unlock of a read lock"),
+}
+
+// nestedLockErrors maps the method a held lock was acquired with to the error reported when the same mutex is
+// acquired again with each lock method. Try-locking a held lock cannot deadlock, but a try-lock of a lock held for
+// writing always fails, and one of a lock held for reading is just as suspicious.
+var nestedLockErrors = map[string]map[string]error{
+	"RLock": {"RLock": errNestedRLock, "Lock": errLockUpgrade, "TryRLock": errTryLockHeld, "TryLock": errTryLockHeld},
+	"Lock":  {"RLock": errNestedLock, "Lock": errNestedLock, "TryRLock": errTryLockHeld, "TryLock": errTryLockHeld},
+}
+
 var once bool = true
 
 func run(pass *analysis.Pass) (interface{}, error) {
@@ -37,125 +77,64 @@ func run(pass *analysis.Pass) (interface{}, error) {
 	if !ok {
 		return nil, errors.New("analyzer is not type *inspector.Inspector")
 	}
+	cfgs, ok := pass.ResultOf[ctrlflow.Analyzer].(*ctrlflow.CFGs)
+	if !ok {
+		return nil, errors.New("analyzer is not type *ctrlflow.CFGs")
+	}
+	summaries, ok := pass.ResultOf[summariesAnalyzer].(*lockSummaries)
+	if !ok {
+		return nil, errors.New("analyzer is not type *lockSummaries")
+	}
+	switch engine {
+	case "ssa":
+		return runSSA(pass, summaries)
+	case "ast":
+	default:
+		return nil, fmt.Errorf("unknown engine %q", engine)
+	}
 
-	// filters out other pieces of source code except for function/method calls
+	// every function body, including function literals, starts out without any locks held
 	nodeFilter := []ast.Node{
-		(*ast.CallExpr)(nil),
-		(*ast.DeferStmt)(nil),
 		(*ast.FuncDecl)(nil),
 		(*ast.FuncLit)(nil),
-		(*ast.File)(nil),
-		(*ast.ReturnStmt)(nil),
 	}
-
-	// debug := &debugHelper{
-	// 	pass: pass,
-	// }
-	var keepTrackOf tracker
 	inspect.Preorder(nodeFilter, func(node ast.Node) {
-		if keepTrackOf.funcLitEnd.IsValid() && node.Pos() <= keepTrackOf.funcLitEnd {
-			return
-		} else {
-			keepTrackOf.funcLitEnd = token.NoPos
-		}
-		if keepTrackOf.deferEnd.IsValid() && node.Pos() > keepTrackOf.deferEnd {
-			keepTrackOf.deferEnd = token.NoPos
-		} else if keepTrackOf.deferEnd.IsValid() {
-			return
-		}
-		if keepTrackOf.retEnd.IsValid() && node.Pos() > keepTrackOf.retEnd {
-			keepTrackOf.retEnd = token.NoPos
-			keepTrackOf.incFRU()
-		}
+		var g *cfg.CFG
+		var body *ast.BlockStmt
 		switch stmt := node.(type) {
-		case *ast.CallExpr:
-			call := getCallInfo(pass.TypesInfo, stmt)
-			if call == nil {
-				break
-			}
-			name := call.id
-			selMap := mapSelTypes(stmt, pass)
-			if selMap == nil {
-				break
-			}
-			if keepTrackOf.foundRLock > 0 && keepTrackOf.rLockSelector.isEqual(selMap, 0) {
-				pass.Reportf(
-					node.Pos(),
-					fmt.Sprintf(
-						"%v",
-						errNestedRLock,
-					),
-				)
-			} else if keepTrackOf.foundRLock > 0 {
-				if stack := hasNestedRLock(keepTrackOf.rLockSelector, selMap, call, inspect, pass, make(map[string]bool)); stack != "" {
-					pass.Reportf(
-						node.Pos(),
-						fmt.Sprintf(
-							"%v\n%v",
-							errNestedRLock,
-							stack,
-						),
-					)
-				}
-			}
-			if name == "RLock" && keepTrackOf.foundRLock == 0 {
-				keepTrackOf.rLockSelector = selMap
-				keepTrackOf.incFRU()
-			}
-			if name == "RUnlock" && keepTrackOf.rLockSelector.isEqual(selMap, 1) {
-				keepTrackOf.deincFRU()
-				//debug.log(stmt, 10, 19, "/Users/chase/Documents/dev/personalGoExperiments/learnAnalysis/sampleLock2/lock.go", "%v\n", "deincr")
-			}
-		case *ast.File:
-			keepTrackOf = tracker{}
 		case *ast.FuncDecl:
-			keepTrackOf = tracker{}
-			keepTrackOf.funcEnd = stmt.End()
+			g, body = cfgs.FuncDecl(stmt), stmt.Body
 		case *ast.FuncLit:
-			if keepTrackOf.funcLitEnd == token.NoPos {
-				keepTrackOf.funcLitEnd = stmt.End()
-			}
-		case *ast.DeferStmt:
-			call := getCallInfo(pass.TypesInfo, stmt.Call)
-			if keepTrackOf.deferEnd == token.NoPos {
-				keepTrackOf.deferEnd = stmt.End()
+			g, body = cfgs.FuncLit(stmt), stmt.Body
+		}
+		if g != nil {
+			flow := &lockFlow{
+				pass:      pass,
+				inspect:   inspect,
+				summaries: summaries,
+				body:      body,
+				in:        make(map[*cfg.Block]lockState),
+				spawns:    make(map[string]spawn),
 			}
-			if call != nil && call.id == "RUnlock" {
-				keepTrackOf.deferredRUnlock = true
+			flow.onCall = func(state lockState, lock accessPath, call *callInfo) {
+				if _, isUnlock := releasedMethods[call.id]; isUnlock {
+					flow.checkRelease(state, lock, call)
+				} else {
+					flow.checkCall(state.held, lock, call)
+				}
 			}
-		case *ast.ReturnStmt:
-			if keepTrackOf.deferredRUnlock && keepTrackOf.retEnd == token.NoPos {
-				keepTrackOf.deincFRU()
-				keepTrackOf.retEnd = stmt.End()
+			flow.onExit = flow.checkExit
+			flow.onWait = flow.checkWait
+			flow.onLit = func(held lockset, lit *ast.FuncLit, call *ast.CallExpr) {
+				flow.checkLocks(held, call, summaries.litLocks(lit, "func literal", call))
 			}
+			flow.solve(g)
+			flow.report(g)
 		}
 	})
 	return nil, nil
 }
 
-type tracker struct {
-	funcEnd         token.Pos
-	retEnd          token.Pos
-	deferEnd        token.Pos
-	funcLitEnd      token.Pos
-	deferredRUnlock bool
-	foundRLock      int
-	rLockSelector   *selIdentList
-}
-
-func (t tracker) toString() string {
-	return fmt.Sprintf("funcEnd:%v\nretEnd:%v\ndeferEnd:%v\ndeferredRU:%v\nfoundRLock:%v\n", t.funcEnd, t.retEnd, t.deferEnd, t.deferredRUnlock, t.foundRLock)
-}
-
-func (t *tracker) deincFRU() {
-	if t.foundRLock > 0 {
-		t.foundRLock -= 1
-	}
-}
-func (t *tracker) incFRU() {
-	t.foundRLock += 1
-}
-
 // debug functions and helpers
 type debugHelper struct {
 	pass *analysis.Pass
@@ -173,155 +152,53 @@ func (d *debugHelper) log(node ast.Node, a int, b int, fileName string, format s
 	}
 }
 
-type selIdentNode struct {
-	next   *selIdentNode
-	this   *ast.Ident
-	typObj types.Object
-}
-
-type selIdentList struct {
-	start        *selIdentNode
-	length       int
-	current      *selIdentNode
-	currentIndex int
-}
-
-func (s *selIdentList) next() (n *selIdentNode) {
-	n = s.current.next
-	if n != nil {
-		s.current = n
-		s.currentIndex++
-	}
-	return n
-}
+type callInfo struct {
+	call *ast.CallExpr
+	id   string       // type ID [either the name (if the function is exported) or the package/name if otherwise] of the function/method
+	typ  types.Type   // type of the method receiver (nil if a function)
+	obj  types.Object // the called function/method, used to look up facts of functions declared in other packages
 
-func (s *selIdentList) reset() {
-	s.current = s.start
-	s.currentIndex = 0
-}
+	// fun is the method value or method expression the called function was selected by, if the call is made through a
+	// variable it was bound to or it is passed to call as a callback. It is nil if the function is selected by call.Fun.
+	fun *ast.SelectorExpr
 
-func (s *selIdentList) isEqual(s2 *selIdentList, offset int) bool {
-	if s2 == nil || (s.length != s2.length) {
-		return false
-	}
-	s.reset()
-	s2.reset()
-	for i := true; i; {
-		if !s.current.isEqual(s2.current) {
-			return false
-		}
-		if s.currentIndex < s.length-offset-1 && s.next() != nil {
-			s2.next()
-		} else {
-			i = false
-		}
-	}
-	return true
+	dispatched bool // true if this is one of the implementations an interface method call was resolved to
+	callback   bool // true if the function is passed to call as an argument, so the arguments it is called with are unknown
 }
 
-func (s *selIdentList) getSub(s2 *selIdentList) *selIdentList {
-	if s2 == nil || s2.length > s.length {
-		return nil
-	}
-	s.reset()
-	s2.reset()
-	for i := true; i; {
-		if !s.current.isEqual(s2.current) {
-			return nil
-		}
-		if s2.currentIndex != s2.length-2 { // might want to add a selNode.prev() func
-			s.next()
-			s2.next()
-		} else {
-			i = false
-		}
-	}
-	return &selIdentList{
-		start:        s.current,
-		length:       s.length - s.currentIndex,
-		current:      s.current,
-		currentIndex: 0,
-	}
+// returns true if callInfo represents a method, false if it is a function
+func (c *callInfo) isMethod() bool {
+	return c.typ != nil
 }
 
-func (s *selIdentList) changeRoot(r *ast.Ident, t types.Object) {
-	selNode := &selIdentNode{
-		this:   r,
-		next:   s.start.next,
-		typObj: t,
-	}
-	if s.start == s.current {
-		s.start = selNode
-		s.current = selNode
-	} else {
-		s.start = selNode
-	}
+// returns true if callInfo represents a call to an interface method
+func (c *callInfo) isInterfaceCall() bool {
+	return c.isMethod() && types.IsInterface(c.typ)
 }
 
-func (s selIdentList) String() (str string) {
-	var temp *selIdentNode = s.start
-	str = fmt.Sprintf("length: %v\n[\n", s.length)
-	for i := 0; temp != nil; i++ {
-		if i == s.currentIndex {
-			str += "*"
-		}
-		str += fmt.Sprintf("%v: %v\n", i, temp)
-		temp = temp.next
+// selector returns the expression the called function is selected by
+func (c *callInfo) selector() ast.Expr {
+	if c.fun != nil {
+		return c.fun
 	}
-	str += "]"
-	return str
-}
-
-func (s *selIdentNode) isEqual(s2 *selIdentNode) bool {
-	return (s.this.Name == s2.this.Name) && (s.typObj == s2.typObj)
-}
-
-func (s selIdentNode) String() string {
-	return fmt.Sprintf("{ ident: '%v', type: '%v' }", s.this, s.typObj)
+	return c.call.Fun
 }
 
-func mapSelTypes(c *ast.CallExpr, pass *analysis.Pass) *selIdentList {
-	list := &selIdentList{}
-	valid := list.recurMapSelTypes(c.Fun, nil, pass.TypesInfo)
-	if !valid {
+// args returns the arguments the called function is called with, or nil if they are unknown
+func (c *callInfo) args() []ast.Expr {
+	if c.callback {
 		return nil
 	}
-	return list
+	return c.call.Args
 }
 
-func (l *selIdentList) recurMapSelTypes(e ast.Expr, next *selIdentNode, t *types.Info) bool {
-	expr := astutil.Unparen(e)
-	l.length++
-	s := &selIdentNode{next: next}
-	switch stmt := expr.(type) {
-	case *ast.Ident:
-		s.this = stmt
-		s.typObj = t.ObjectOf(stmt)
-	case *ast.SelectorExpr:
-		s.this = stmt.Sel
-		if sel, ok := t.Selections[stmt]; ok {
-			s.typObj = sel.Obj() // method or field
-		} else {
-			s.typObj = t.Uses[stmt.Sel] // qualified identifier?
-		}
-		return l.recurMapSelTypes(stmt.X, s, t)
-	default:
-		return false
+// name returns the name of the called function for stack traces. Implementations of interface methods use their
+// full name, so the report says which implementation was called.
+func (c *callInfo) name() string {
+	if f, ok := c.obj.(*types.Func); ok && c.dispatched {
+		return f.FullName()
 	}
-	l.current = s
-	l.start = s
-	return true
-}
-
-type callInfo struct {
-	call *ast.CallExpr
-	id   string     // type ID [either the name (if the function is exported) or the package/name if otherwise] of the function/method
-	typ  types.Type // type of the method receiver (nil if a function)
-}
-
-// returns true if callInfo represents a method, false if it is a function
-func (c *callInfo) isMethod() bool {
-	return c.typ != nil
+	return c.id
 }
 
 func (c *callInfo) String() string {
@@ -339,135 +216,300 @@ func getCallInfo(tInfo *types.Info, call *ast.CallExpr) (c *callInfo) {
 	if f == nil {
 		return nil
 	}
+	if id, ok := ast.Unparen(call.Fun).(*ast.Ident); ok {
+		if sel := boundMethod(tInfo, id); sel != nil { // f := r.GetResource; f()
+			f = tInfo.Selections[sel].Obj()
+			c.fun = sel
+		}
+	}
 	if _, isBuiltin := f.(*types.Builtin); isBuiltin {
 		return nil
 	}
 	s, ok := f.Type().(*types.Signature)
 	if ok {
-		if interfaceMethod(s) {
-			return nil
-		}
 		if r := s.Recv(); r != nil {
 			c.typ = r.Type()
 		}
 	}
 	c.id = f.Id()
+	c.obj = f
 	return c
 }
 
-func interfaceMethod(s *types.Signature) bool {
-	recv := s.Recv()
-	return recv != nil && types.IsInterface(recv.Type())
-}
-
-// hasNestedRLock takes a call expression represented by callInfo as input and returns a stack trace of the nested or recursive RLock within
-// that call expression. If the call expression does not contain a nested or recursive RLock, hasNestedRLock returns an empty string.
-// hasNestedRLock finds a nested or recursive RLock by recursively calling itself on any functions called by the function/method represented
-// by callInfo.
-func hasNestedRLock(fullRLockSelector *selIdentList, compareMap *selIdentList, call *callInfo, inspect *inspector.Inspector, pass *analysis.Pass, hist map[string]bool) (retStack string) {
-	// debug := debugHelper{
-	// 	pass: pass,
-	// }
-	var rLockSelector *selIdentList
-	f := pass.Fset
-	tInfo := pass.TypesInfo
-	cH := callHelper{
-		call: call.call,
-		fset: pass.Fset,
-	}
-	var node ast.Node = cH.identifyFuncLitBlock(cH.call.Fun) // this seems a bit redundant
-	var recv *ast.Ident
-	if node == (*ast.BlockStmt)(nil) {
-		subMap := fullRLockSelector.getSub(compareMap)
-		if subMap != nil {
-			rLockSelector = subMap
-		} else {
-			return "" // if this is not a local function literal call, and the selectors don't match up, then we can just return
+// methodCallInfo returns the callInfo of the method value or method expression e passed to call as a callback, or nil
+// if e is neither
+func methodCallInfo(tInfo *types.Info, call *ast.CallExpr, e ast.Expr) *callInfo {
+	sel, ok := ast.Unparen(e).(*ast.SelectorExpr)
+	if !ok {
+		return nil
+	}
+	selection := tInfo.Selections[sel]
+	if selection == nil || selection.Kind() == types.FieldVal {
+		return nil
+	}
+	f := selection.Obj()
+	return &callInfo{
+		call:     call,
+		id:       f.Id(),
+		typ:      f.Type().(*types.Signature).Recv().Type(),
+		obj:      f,
+		fun:      sel,
+		callback: true,
+	}
+}
+
+// boundMethod returns the method value or method expression the variable id was declared with (see declaredValue), or
+// nil if it was declared with anything else. Lock methods are left to the lock tracking.
+func boundMethod(tInfo *types.Info, id *ast.Ident) *ast.SelectorExpr {
+	sel, ok := declaredValue(id).(*ast.SelectorExpr)
+	if !ok {
+		return nil
+	}
+	if selection := tInfo.Selections[sel]; selection == nil || selection.Kind() == types.FieldVal || isLockMethod(sel.Sel.Name) {
+		return nil
+	}
+	return sel
+}
+
+// targets returns the callInfos of the functions the call may run. For an interface method call these are the
+// implementations of the method, otherwise it is just c.
+func (c *callInfo) targets(impls *implementers) []*callInfo {
+	if !c.isInterfaceCall() {
+		return []*callInfo{c}
+	}
+	var targets []*callInfo
+	for _, f := range impls.of(c.typ, c.obj.(*types.Func)) {
+		targets = append(targets, &callInfo{
+			call:       c.call,
+			id:         f.Id(),
+			typ:        f.Type().(*types.Signature).Recv().Type(),
+			obj:        f,
+			fun:        c.fun,
+			dispatched: true,
+			callback:   c.callback,
+		})
+	}
+	return targets
+}
+
+// implementers resolves the methods of interface types to the methods of every concrete type, declared in a package or
+// the packages it imports, that implements them (class hierarchy analysis). The method sets of the candidate types are
+// built once per package, and the implementations of every method once per interface type.
+type implementers struct {
+	pkg        *types.Package
+	candidates []candidate  // built on first use
+	memo       typeutil.Map // interface type -> map[method id][]*types.Func
+}
+
+// candidate is a concrete named type T that may implement an interface, with the method sets of T and *T. The methods
+// of a generic type are the ones of its generic declaration, and the type is instantiated for every interface (see
+// instantiateFor).
+type candidate struct {
+	named *types.Named // the generic type, or nil if the type is not generic
+	sets  [2]*types.MethodSet
+}
+
+func newImplementers(pkg *types.Package) *implementers {
+	return &implementers{pkg: pkg}
+}
+
+// of returns the methods method of the interface type iface may dispatch to
+func (m *implementers) of(iface types.Type, method *types.Func) []*types.Func {
+	byMethod, _ := m.memo.At(iface).(map[string][]*types.Func)
+	if byMethod == nil {
+		byMethod = make(map[string][]*types.Func)
+		m.memo.Set(iface, byMethod)
+	}
+	impls, found := byMethod[method.Id()]
+	if !found {
+		impls = m.resolve(iface, method)
+		byMethod[method.Id()] = impls
+	}
+	return impls
+}
+
+// resolve returns the methods method of the interface type iface may dispatch to, looking them up in the method sets of
+// the candidate types
+func (m *implementers) resolve(iface types.Type, method *types.Func) (impls []*types.Func) {
+	i, ok := iface.Underlying().(*types.Interface)
+	if !ok {
+		return nil
+	}
+	if !i.IsMethodSet() { // the constraint of a type parameter, like interface{ *T; lockRead() }
+		i = methodsOf(i)
+	}
+	if m.candidates == nil {
+		m.candidates = candidateSets(m.pkg)
+	}
+	found := make(map[*types.Func]bool)
+	for _, c := range m.candidates {
+		sets, ok := c.sets, true
+		if c.named != nil {
+			sets, ok = c.instantiateFor(i)
 		}
-		node = findCallDeclarationNode(call, inspect, pass.TypesInfo)
-		if node == (*ast.FuncDecl)(nil) {
-			return ""
-		} else if castedNode := node.(*ast.FuncDecl); castedNode.Recv != nil {
-			recv = castedNode.Recv.List[0].Names[0]
-			rLockSelector.changeRoot(recv, pass.TypesInfo.ObjectOf(recv))
+		if !ok {
+			continue
 		}
-	} else {
-		rLockSelector = fullRLockSelector // no need to find a submap, since this is a local function call
-	}
-	addition := fmt.Sprintf("\t%q at %v\n", call.id, f.Position(call.call.Pos()))
-	ast.Inspect(node, func(iNode ast.Node) bool {
-		switch stmt := iNode.(type) {
-		case *ast.CallExpr:
-			c := getCallInfo(tInfo, stmt)
-			if c == nil {
-				return false
+		for _, ms := range sets { // the method set of T is part of *T's
+			if !implements(ms, i) {
+				continue
 			}
-			name := c.id
-			selMap := mapSelTypes(stmt, pass)
-			if rLockSelector.isEqual(selMap, 0) { // if the method found is an RLock method
-				retStack += addition + fmt.Sprintf("\t%q at %v\n", name, f.Position(iNode.Pos()))
-			} else if name != "RUnlock" { // name should not equal the previousName to prevent infinite recursive loop
-				nt := c.String()
-				if !hist[nt] { // make sure we are not in an infinite recursive loop
-					hist[nt] = true
-					stack := hasNestedRLock(rLockSelector, selMap, c, inspect, pass, hist)
-					delete(hist, nt)
-					if stack != "" {
-						retStack += addition + stack
-					}
+			if sel := ms.Lookup(method.Pkg(), method.Name()); sel != nil {
+				if f, ok := sel.Obj().(*types.Func); ok && !found[f] {
+					found[f] = true
+					impls = append(impls, f)
 				}
 			}
+			break
 		}
-		return true
-	})
-	return retStack
+	}
+	return impls
 }
 
-// findCallDeclarationNode takes a callInfo struct and inspects the AST of the package
-// to find a matching method or function declaration. It returns this declaration of type *ast.FuncDecl
-func findCallDeclarationNode(c *callInfo, inspect *inspector.Inspector, tInfo *types.Info) *ast.FuncDecl {
-	var retNode *ast.FuncDecl = nil
-	nodeFilter := []ast.Node{
-		(*ast.FuncDecl)(nil),
-	}
-	inspect.Preorder(nodeFilter, func(node ast.Node) {
-		funcDec, _ := node.(*ast.FuncDecl)
-		name := tInfo.ObjectOf(funcDec.Name).Id()
-		if c.isMethod() { // are we looking for a method of a specific type?
-			if funcDec.Recv == nil { // if this particular call declaration isn't even a method, we can move on
-				return
+// candidateSets returns the candidates declared in pkg or the packages it imports
+func candidateSets(pkg *types.Package) (candidates []candidate) {
+	for _, p := range append([]*types.Package{pkg}, pkg.Imports()...) {
+		scope := p.Scope()
+		for _, name := range scope.Names() {
+			tn, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || tn.IsAlias() || types.IsInterface(tn.Type()) {
+				continue
 			}
-			if t := tInfo.TypeOf(funcDec.Recv.List[0].Type); !types.Identical(t, c.typ) { // if the found type does not equal the target type, we can move on
-				// fmt.Printf("Found call declaration of type %v\n", t)
-				return
+			c := candidate{sets: [2]*types.MethodSet{types.NewMethodSet(tn.Type()), types.NewMethodSet(types.NewPointer(tn.Type()))}}
+			if named, ok := tn.Type().(*types.Named); ok && named.TypeParams().Len() > 0 {
+				c.named = named
 			}
-		} else if funcDec.Recv != nil { // if we are looking for a function, ignore methods
-			return
+			candidates = append(candidates, c)
 		}
-		if c.id == name {
-			retNode = funcDec
+	}
+	return candidates
+}
+
+// instantiateFor returns the method sets of the instance of the generic candidate c that may implement the interface
+// i, or false if there is none. The type arguments are inferred from the signatures of the methods of i, and the type
+// parameters they do not determine are left as they are: cache[K, V].get(K) V implements interface{ get(string) int }
+// as cache[string, int].
+func (c candidate) instantiateFor(i *types.Interface) ([2]*types.MethodSet, bool) {
+	args := make([]types.Type, c.named.TypeParams().Len())
+	for k := range args {
+		args[k] = c.named.TypeParams().At(k)
+	}
+	for k := 0; k < i.NumMethods(); k++ {
+		m := i.Method(k)
+		sel := c.sets[1].Lookup(m.Pkg(), m.Name())
+		if sel == nil {
+			return [2]*types.MethodSet{}, false
 		}
-	})
-	return retNode
+		sig, isig := sel.Obj().Type().(*types.Signature), m.Type().(*types.Signature)
+		bindTypeArgs(args, sig.Params(), isig.Params())
+		bindTypeArgs(args, sig.Results(), isig.Results())
+	}
+	inst, err := types.Instantiate(nil, c.named, args, true)
+	if err != nil {
+		return [2]*types.MethodSet{}, false
+	}
+	return [2]*types.MethodSet{types.NewMethodSet(inst), types.NewMethodSet(types.NewPointer(inst))}, true
+}
+
+// bindTypeArgs binds the receiver type parameters in the types of generic to the matching parts of the types of
+// concrete, as far as their structures match, and records them in args
+func bindTypeArgs(args []types.Type, generic, concrete *types.Tuple) {
+	if generic.Len() != concrete.Len() {
+		return
+	}
+	for k := 0; k < generic.Len(); k++ {
+		bindTypeArg(args, generic.At(k).Type(), concrete.At(k).Type())
+	}
+}
+
+func bindTypeArg(args []types.Type, generic, concrete types.Type) {
+	switch g := generic.(type) {
+	case *types.TypeParam:
+		if g.Index() < len(args) {
+			args[g.Index()] = concrete
+		}
+	case *types.Pointer:
+		if c, ok := concrete.(*types.Pointer); ok {
+			bindTypeArg(args, g.Elem(), c.Elem())
+		}
+	case *types.Slice:
+		if c, ok := concrete.(*types.Slice); ok {
+			bindTypeArg(args, g.Elem(), c.Elem())
+		}
+	case *types.Array:
+		if c, ok := concrete.(*types.Array); ok {
+			bindTypeArg(args, g.Elem(), c.Elem())
+		}
+	case *types.Chan:
+		if c, ok := concrete.(*types.Chan); ok {
+			bindTypeArg(args, g.Elem(), c.Elem())
+		}
+	case *types.Map:
+		if c, ok := concrete.(*types.Map); ok {
+			bindTypeArg(args, g.Key(), c.Key())
+			bindTypeArg(args, g.Elem(), c.Elem())
+		}
+	}
+}
+
+// implements returns true if the method set ms has every method of the interface i, with identical signatures
+func implements(ms *types.MethodSet, i *types.Interface) bool {
+	for k := 0; k < i.NumMethods(); k++ {
+		m := i.Method(k)
+		sel := ms.Lookup(m.Pkg(), m.Name())
+		if sel == nil || !types.Identical(sel.Obj().Type(), m.Type()) {
+			return false
+		}
+	}
+	return true
+}
+
+// methodsOf returns the interface made of the methods of the constraint i, leaving out its type terms. A method called
+// on a type parameter may run the method of any type having the methods of its constraint, which cannot tell which
+// types its type terms stand for without the type arguments of the call.
+func methodsOf(i *types.Interface) *types.Interface {
+	methods := make([]*types.Func, i.NumMethods())
+	for k := range methods {
+		methods[k] = i.Method(k)
+	}
+	return types.NewInterfaceType(methods, nil).Complete()
+}
+
+// isLockMethod returns true if name is the name of a method that acquires or releases a lock
+func isLockMethod(name string) bool {
+	switch name {
+	case "RLock", "Lock", "RUnlock", "Unlock":
+		return true
+	}
+	return false
 }
 
 type callHelper struct {
-	call *ast.CallExpr
-	fset *token.FileSet
+	call  *ast.CallExpr
+	fset  *token.FileSet
+	info  *types.Info
+	funcs *funcDecls // resolves function literals stored in struct fields, which are not followed if nil
 }
 
 func (c callHelper) identifyFuncLitBlock(expr ast.Expr) *ast.BlockStmt {
-	switch stmt := expr.(type) {
+	switch stmt := ast.Unparen(expr).(type) {
 	case *ast.FuncLit:
 		return stmt.Body
 	case *ast.CallExpr:
 		return nil
+	case *ast.SelectorExpr: // s.f(), where a function literal is stored in the field f of s
+		if c.funcs == nil {
+			return nil
+		}
+		if lit := c.funcs.fieldLit(c.info, stmt); lit != nil {
+			return lit.Body
+		}
 	case *ast.Ident:
 		if stmt.Obj != nil {
 			switch objDecl := stmt.Obj.Decl.(type) {
 			case *ast.ValueSpec:
 				identIndex := findIdentIndex(stmt, objDecl.Names)
-				if identIndex != -1 {
+				if identIndex != -1 && identIndex < len(objDecl.Values) { // var fn func() has no value to follow
 					value := objDecl.Values[identIndex]
 					return c.identifyFuncLitBlock(value)
 				}
@@ -515,8 +557,17 @@ func findIdentIndexFromExpr(id *ast.Ident, exprs []ast.Expr) int {
 	return -1
 }
 
-func findSelectorIndex(expr ast.Expr, exprs []ast.Expr) int {
-	return 0 // a place holder for later
+// findSelectorIndex returns the index of the selector expression in exprs selecting the same names from the same
+// identifier as expr, or -1 if there is none
+func findSelectorIndex(expr *ast.SelectorExpr, exprs []ast.Expr) int {
+	for i, v := range exprs {
+		if val, ok := ast.Unparen(v).(*ast.SelectorExpr); ok && val.Sel.Name == expr.Sel.Name {
+			if findExprIndex(ast.Unparen(expr.X), []ast.Expr{ast.Unparen(val.X)}) == 0 {
+				return i
+			}
+		}
+	}
+	return -1
 }
 
 /*
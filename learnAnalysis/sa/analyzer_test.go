@@ -1,11 +1,257 @@
 package sa
 
 import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
+	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/analysis/analysistest"
+	"golang.org/x/tools/go/analysis/checker"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/packages"
 )
 
 func TestAnalyzer(t *testing.T) {
-	analysistest.Run(t, analysistest.TestData(), Analyzer)
+	analysistest.Run(t, analysistest.TestData(), Analyzer, "nestedrlock", "crosspkg", "ifaces", "upgrade", "relock", "leaks", "unlocks", "paths", "methodvalues", "outliers", "helpers", "lockers", "indexed", "goroutines", "closures", "embedded", "generics", "trylock")
+}
+
+func TestSSAEngine(t *testing.T) {
+	if err := Analyzer.Flags.Set("engine", "ssa"); err != nil {
+		t.Fatal(err)
+	}
+	defer Analyzer.Flags.Set("engine", "ast")
+	analysistest.Run(t, analysistest.TestData(), Analyzer, "nestedrlock", "crosspkg", "ifaces", "upgrade", "relock", "leaks", "unlocks", "ssalocks", "helpers", "lockers", "indexed", "embedded", "generics", "trylock")
+}
+
+func TestMayAlias(t *testing.T) {
+	if err := Analyzer.Flags.Set("mayalias", "true"); err != nil {
+		t.Fatal(err)
+	}
+	defer Analyzer.Flags.Set("mayalias", "false")
+	analysistest.Run(t, analysistest.TestData(), Analyzer, "mayalias")
+	if err := Analyzer.Flags.Set("engine", "ssa"); err != nil {
+		t.Fatal(err)
+	}
+	defer Analyzer.Flags.Set("engine", "ast")
+	analysistest.Run(t, analysistest.TestData(), Analyzer, "mayalias")
+}
+
+// TestRelated checks the frames related to nested lock and wait diagnostics, which analysistest does not match
+func TestRelated(t *testing.T) {
+	want := map[string][]string{ // position of the diagnostic -> positions and messages of its related frames
+		"upgrade.go:34": {"upgrade.go:33 RLock", "upgrade.go:34 upgrade.set", "upgrade.go:16 Lock"},
+		"upgrade.go:41": {"upgrade.go:39 RLock", "upgrade.go:41 upgrade.setThroughHelper", "upgrade.go:22 upgrade.set", "upgrade.go:16 Lock"},
+		"upgrade.go:46": {"upgrade.go:45 RLock", "upgrade.go:46 SetResource", "types.go:18 Lock"},
+		"goroutines.go:63": {"goroutines.go:57 Lock", "goroutines.go:59 go", "goroutines.go:59 func literal",
+			"goroutines.go:61 goroutines.worker", "goroutines.go:17 Lock", "goroutines.go:63 (*sync.WaitGroup).Wait"},
+	}
+	checkRelated(t, analysistest.Run(t, analysistest.TestData(), Analyzer, "upgrade", "goroutines"), want)
+}
+
+// TestResolveFrame checks that the positions of frames read from facts are only resolved within the lines they name
+func TestResolveFrame(t *testing.T) {
+	fset := token.NewFileSet()
+	f := fset.AddFile("a.go", -1, 20)
+	f.SetLines([]int{0, 10})
+	files := map[string]*token.File{"a.go": f}
+	for pos, want := range map[string]token.Pos{
+		"a.go:1:1":  f.Pos(0),
+		"a.go:1:10": f.Pos(9),
+		"a.go:1:11": token.NoPos, // past the end of the line
+		"a.go:2:3":  f.Pos(12),
+		"a.go:2:11": f.Pos(20), // the end of the file
+		"a.go:2:12": token.NoPos,
+		"a.go:2:0":  token.NoPos,
+		"a.go:3:1":  token.NoPos,
+		"b.go:1:1":  token.NoPos,
+		"a.go":      token.NoPos,
+	} {
+		if got := (callFrame{Name: "Lock", Pos: pos}).resolve(files); got != want {
+			t.Errorf("%v: resolved to %v, want %v", pos, got, want)
+		}
+	}
+}
+
+// checkRelated checks that the diagnostics of results at the positions in want have the related frames listed there
+func checkRelated(t *testing.T, results []*analysistest.Result, want map[string][]string) {
+	t.Helper()
+	for _, result := range results {
+		for _, d := range result.Diagnostics {
+			posn := result.Pass.Fset.Position(d.Pos)
+			key := fmt.Sprintf("%v:%v", filepath.Base(posn.Filename), posn.Line)
+			if want[key] == nil {
+				continue
+			}
+			var got []string
+			for _, related := range d.Related {
+				posn := result.Pass.Fset.Position(related.Pos)
+				got = append(got, fmt.Sprintf("%v:%v %v", filepath.Base(posn.Filename), posn.Line, related.Message))
+			}
+			if !reflect.DeepEqual(got, want[key]) {
+				t.Errorf("%v: related frames are %q, want %q", key, got, want[key])
+			}
+			delete(want, key)
+		}
+	}
+	for key := range want {
+		t.Errorf("%v: no diagnostic reported", key)
+	}
+}
+
+func TestSummariesAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), summariesAnalyzer, "lockfacts")
+}
+
+// TestAnalyzersTogether runs the analyzers of the command in a single checker, as multichecker does: they share
+// summariesAnalyzer, and each must report the same diagnostics as when it runs alone
+func TestAnalyzersTogether(t *testing.T) {
+	analyzers := []*analysis.Analyzer{Analyzer, OrderAnalyzer, BlockingAnalyzer}
+	pkgs := loadTestdata(t, analysistest.TestData(), "crosspkg", "lockorder", "blocking")
+	together := diagnostics(t, analyzers, pkgs)
+	for _, a := range analyzers {
+		alone := diagnostics(t, []*analysis.Analyzer{a}, pkgs)
+		if len(alone[a]) == 0 {
+			t.Errorf("%v: no diagnostics reported", a.Name)
+		}
+		if !reflect.DeepEqual(together[a], alone[a]) {
+			t.Errorf("%v: diagnostics are %q when run with the other analyzers, want %q", a.Name, together[a], alone[a])
+		}
+	}
+}
+
+// loadTestdata loads the packages matching patterns from the GOPATH directory dir, along with their dependencies, the
+// way analysistest does
+func loadTestdata(tb testing.TB, dir string, patterns ...string) []*packages.Package {
+	tb.Helper()
+	cfg := &packages.Config{
+		Mode: packages.LoadAllSyntax,
+		Dir:  filepath.Join(dir, "src"),
+		Env:  append(os.Environ(), "GOPATH="+dir, "GO111MODULE=off", "GOPROXY=off"),
+	}
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if packages.PrintErrors(pkgs) > 0 {
+		tb.Fatal("packages contain errors")
+	}
+	return pkgs
+}
+
+// diagnostics runs analyzers on pkgs and returns the positions and messages of the diagnostics of each analyzer
+func diagnostics(t *testing.T, analyzers []*analysis.Analyzer, pkgs []*packages.Package) map[*analysis.Analyzer][]string {
+	t.Helper()
+	graph, err := checker.Analyze(analyzers, pkgs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := make(map[*analysis.Analyzer][]string)
+	for _, act := range graph.Roots {
+		if act.Err != nil {
+			t.Fatalf("%v: %v", act, act.Err)
+		}
+		for _, d := range act.Diagnostics {
+			posn := act.Package.Fset.Position(d.Pos)
+			found[act.Analyzer] = append(found[act.Analyzer], fmt.Sprintf("%v:%v: %v", filepath.Base(posn.Filename), posn.Line, d.Message))
+		}
+	}
+	return found
+}
+
+func TestOrderAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), OrderAnalyzer, "lockorder")
+}
+
+func TestBlockingAnalyzer(t *testing.T) {
+	want := map[string][]string{ // position of the diagnostic -> positions and messages of its related frames
+		"blocking.go:109": {"blocking.go:106 Lock", "blocking.go:109 blocking.settle", "blocking.go:102 time.Sleep"},
+	}
+	checkRelated(t, analysistest.Run(t, analysistest.TestData(), BlockingAnalyzer, "blocking"), want)
+}
+
+func TestBlockingFuncs(t *testing.T) {
+	funcs := BlockingAnalyzer.Flags.Lookup("funcs").Value.String()
+	if err := BlockingAnalyzer.Flags.Set("funcs", "blockingfuncs.fetch"); err != nil {
+		t.Fatal(err)
+	}
+	defer BlockingAnalyzer.Flags.Set("funcs", funcs)
+	analysistest.Run(t, analysistest.TestData(), BlockingAnalyzer, "blockingfuncs")
+}
+
+// BenchmarkDeclLookup resolves every call of a synthetic package whose methods each call the next one to its
+// declaration, on a package loaded once so loading does not dominate. "index" builds the funcDecls of the package and
+// looks every call up in it, and "scan" is the baseline it replaced: a walk over the declarations of the package for
+// every call.
+func BenchmarkDeclLookup(b *testing.B) {
+	for _, methods := range []int{1000, 4000} {
+		pkg := loadTestdata(b, syntheticPackage(b, methods), "synthetic")[0]
+		inspect := inspector.New(pkg.Syntax)
+		var calls []*callInfo
+		inspect.Preorder([]ast.Node{(*ast.CallExpr)(nil)}, func(node ast.Node) {
+			if c := getCallInfo(pkg.TypesInfo, node.(*ast.CallExpr)); c != nil && !isLockMethod(c.id) {
+				calls = append(calls, c)
+			}
+		})
+		decls := newFuncDecls(pkg.TypesInfo, inspect)
+		for _, c := range calls {
+			if decl := decls.of(c.obj); decl == nil || decl != scanDecl(inspect, pkg.TypesInfo, c.obj) {
+				b.Fatalf("%v: index and scan disagree", c.name())
+			}
+		}
+		b.Run(fmt.Sprintf("index/methods=%v", methods), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				decls := newFuncDecls(pkg.TypesInfo, inspect)
+				for _, c := range calls {
+					decls.of(c.obj)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("scan/methods=%v", methods), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, c := range calls {
+					scanDecl(inspect, pkg.TypesInfo, c.obj)
+				}
+			}
+		})
+	}
+}
+
+// scanDecl returns the declaration of obj by walking every function declaration of the package, like calls were
+// resolved before funcDecls
+func scanDecl(inspect *inspector.Inspector, info *types.Info, obj types.Object) (decl *ast.FuncDecl) {
+	inspect.Preorder([]ast.Node{(*ast.FuncDecl)(nil)}, func(node ast.Node) {
+		if funcDec := node.(*ast.FuncDecl); info.Defs[funcDec.Name] == obj {
+			decl = funcDec
+		}
+	})
+	return decl
+}
+
+// syntheticPackage writes a package with the given number of methods to a new testdata directory and returns it
+func syntheticPackage(b *testing.B, methods int) string {
+	var src strings.Builder
+	src.WriteString("package synthetic\n\nimport \"sync\"\n\ntype T struct {\n\tmu sync.Mutex\n\tn  int\n}\n")
+	for i := 0; i < methods; i++ {
+		fmt.Fprintf(&src, "\nfunc (t *T) m%v() {\n\tt.mu.Lock()\n\tt.n++\n\tt.mu.Unlock()\n", i)
+		if i+1 < methods {
+			fmt.Fprintf(&src, "\tt.m%v()\n", i+1)
+		}
+		src.WriteString("}\n")
+	}
+	dir := b.TempDir()
+	pkg := filepath.Join(dir, "src", "synthetic")
+	if err := os.MkdirAll(pkg, 0o755); err != nil {
+		b.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(pkg, "synthetic.go"), []byte(src.String()), 0o644); err != nil {
+		b.Fatal(err)
+	}
+	return dir
 }
@@ -0,0 +1,486 @@
+package sa
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/ctrlflow"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/cfg"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// BlockingAnalyzer checks for operations that block until another goroutine or the outside world is ready, made while
+// a lock is held. Every goroutine waiting for the lock is stalled for as long as the operation takes, and deadlocks if
+// the goroutine the operation waits for needs the lock itself.
+var BlockingAnalyzer = &analysis.Analyzer{
+	Name:      "lockblocking",
+	Doc:       "Checks for channel operations, sleeps, waits and I/O reachable while a lock is held",
+	Requires:  []*analysis.Analyzer{inspect.Analyzer, ctrlflow.Analyzer, summariesAnalyzer},
+	Run:       runBlocking,
+	FactTypes: []analysis.Fact{new(blockingFact)},
+}
+
+// blockingFuncs holds the comma-separated full names of the functions that block, as printed by types.Func.FullName.
+// Methods of interfaces, like (net.Conn).Read, match the calls made through the interface.
+var blockingFuncs = strings.Join([]string{
+	"time.Sleep",
+	"(*sync.WaitGroup).Wait",
+	"(*sync.Cond).Wait",
+	"os.ReadFile",
+	"os.WriteFile",
+	"(*os.File).Read",
+	"(*os.File).ReadAt",
+	"(*os.File).Write",
+	"(*os.File).WriteAt",
+	"(*os.File).Sync",
+	"net.Dial",
+	"net.DialTimeout",
+	"(*net.Dialer).Dial",
+	"(*net.Dialer).DialContext",
+	"(net.Conn).Read",
+	"(net.Conn).Write",
+	"(net.Listener).Accept",
+	"net/http.Get",
+	"net/http.Head",
+	"net/http.Post",
+	"(*net/http.Client).Do",
+	"(*net/http.Client).Get",
+	"(*net/http.Client).Head",
+	"(*net/http.Client).Post",
+}, ",")
+
+func init() {
+	BlockingAnalyzer.Flags.StringVar(&blockingFuncs, "funcs", blockingFuncs, "comma-separated full names of the functions that block, like time.Sleep or (*sync.WaitGroup).Wait")
+}
+
+var errBlockingHeld = errors.New("found blocking operation while holding lock")
+
+// condWait is the full name of the method that releases the lock of a sync.Cond while it waits
+const condWait = "(*sync.Cond).Wait"
+
+// blockingFact is exported for every exported function or method that may block when it is called.
+type blockingFact struct {
+	Ops []blockingOp
+}
+
+// blockingOp describes a single blocking operation of a function, either made directly or by the functions it calls.
+type blockingOp struct {
+	Name  string      // the blocking function, like time.Sleep, or the kind of channel operation
+	Stack []callFrame // calls leading to the operation, ending with the operation itself
+
+	// Locker is the Locker a (*sync.Cond).Wait call releases while it waits, ending with "Wait", if it is rooted at the
+	// receiver, a parameter or a package-level variable of the function of the fact. It has no Stack.
+	Locker *lockAcquisition
+
+	// locker is the access path of the Locker a (*sync.Cond).Wait call releases while it waits, ending with "Wait", if
+	// it is known. It is rooted at the variables of the function the summary belongs to, and exported as Locker.
+	locker *accessPath
+}
+
+func (*blockingFact) AFact() {}
+
+func (f *blockingFact) String() string {
+	ops := make([]string, len(f.Ops))
+	for i, op := range f.Ops {
+		ops[i] = op.Name
+	}
+	return "blocks on " + strings.Join(ops, ", ")
+}
+
+func runBlocking(pass *analysis.Pass) (interface{}, error) {
+	inspect, ok := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	if !ok {
+		return nil, errors.New("analyzer is not type *inspector.Inspector")
+	}
+	cfgs, ok := pass.ResultOf[ctrlflow.Analyzer].(*ctrlflow.CFGs)
+	if !ok {
+		return nil, errors.New("analyzer is not type *ctrlflow.CFGs")
+	}
+	summaries, ok := pass.ResultOf[summariesAnalyzer].(*lockSummaries)
+	if !ok {
+		return nil, errors.New("analyzer is not type *lockSummaries")
+	}
+	ops := newBlockingSummaries(pass, summaries)
+
+	// every function body, including function literals, starts out without any locks held
+	nodeFilter := []ast.Node{
+		(*ast.FuncDecl)(nil),
+		(*ast.FuncLit)(nil),
+	}
+	inspect.Preorder(nodeFilter, func(node ast.Node) {
+		var g *cfg.CFG
+		var body *ast.BlockStmt
+		switch funcNode := node.(type) {
+		case *ast.FuncDecl:
+			if funcNode.Name.IsExported() {
+				if blocks := ops.done[funcNode]; len(blocks) > 0 {
+					fact := &blockingFact{Ops: make([]blockingOp, len(blocks))}
+					for i, op := range blocks {
+						fact.Ops[i] = blockingOp{Name: op.Name, Stack: exportFrames(pass.Fset, op.Stack)}
+						if op.locker != nil {
+							if locker, ok := summaries.acquisition(funcNode, summaryLock{accessPath: *op.locker}); ok {
+								fact.Ops[i].Locker = &locker
+							}
+						}
+					}
+					pass.ExportObjectFact(pass.TypesInfo.ObjectOf(funcNode.Name), fact)
+				}
+			}
+			g, body = cfgs.FuncDecl(funcNode), funcNode.Body
+		case *ast.FuncLit:
+			g, body = cfgs.FuncLit(funcNode), funcNode.Body
+		}
+		if g == nil {
+			return
+		}
+		flow := &lockFlow{
+			pass:      pass,
+			inspect:   inspect,
+			summaries: summaries,
+			body:      body,
+			in:        make(map[*cfg.Block]lockState),
+			spawns:    make(map[string]spawn),
+			onNode:    ops.check,
+		}
+		flow.solve(g)
+		flow.report(g)
+	})
+	return nil, nil
+}
+
+// channelOps returns the channel operations of files that block until another goroutine is ready, keyed by the node
+// the control-flow graph runs them at: sends, receives and the channel of a range loop. A select statement blocks as a
+// whole, unless it has a default case, and is keyed by the operation of its first case; the operations of its cases
+// are mapped to an empty name so they are not reported on their own.
+func channelOps(info *types.Info, files []*ast.File) map[ast.Node]string {
+	ops := make(map[ast.Node]string)
+	add := func(node ast.Node, name string) {
+		if _, found := ops[node]; !found {
+			ops[node] = name
+		}
+	}
+	for _, file := range files {
+		ast.Inspect(file, func(node ast.Node) bool {
+			switch n := node.(type) {
+			case *ast.SendStmt:
+				add(n, "channel send")
+			case *ast.UnaryExpr:
+				if n.Op == token.ARROW {
+					add(n, "channel receive")
+				}
+			case *ast.RangeStmt:
+				if t := info.TypeOf(n.X); t != nil {
+					if _, isChan := t.Underlying().(*types.Chan); isChan {
+						add(n.X, "range over channel")
+					}
+				}
+			case *ast.SelectStmt:
+				name := "select"
+				for _, clause := range n.Body.List {
+					if clause.(*ast.CommClause).Comm == nil { // default:
+						name = ""
+					}
+				}
+				for _, clause := range n.Body.List {
+					if op := commOp(clause.(*ast.CommClause).Comm); op != nil {
+						add(op, name)
+						name = ""
+					}
+				}
+			}
+			return true
+		})
+	}
+	return ops
+}
+
+// commOp returns the channel operation of the case comm of a select statement, or nil for the default case
+func commOp(comm ast.Stmt) ast.Node {
+	switch comm := comm.(type) {
+	case *ast.SendStmt: // case ch <- v:
+		return comm
+	case *ast.ExprStmt: // case <-ch:
+		return ast.Unparen(comm.X)
+	case *ast.AssignStmt: // case v, ok := <-ch:
+		if len(comm.Rhs) == 1 {
+			return ast.Unparen(comm.Rhs[0])
+		}
+	}
+	return nil
+}
+
+// blockingSummaries holds the blocking operations of the functions declared in a package
+type blockingSummaries struct {
+	pass  *analysis.Pass
+	locks *lockSummaries
+	funcs map[string]bool     // full names of the blocking functions (see blockingFuncs)
+	chans map[ast.Node]string // blocking channel operations (see channelOps)
+	done  map[*ast.FuncDecl][]blockingOp
+	lits  map[*ast.BlockStmt]bool // function literal bodies being summarized, to stop literals calling themselves
+}
+
+// newBlockingSummaries summarizes the blocking operations of every function declared in the package bottom-up, over
+// the same strongly connected components as the lock summaries: the functions of a component are summarized until
+// their summaries stop changing, so recursive calls see every operation of the functions they call.
+func newBlockingSummaries(pass *analysis.Pass, locks *lockSummaries) *blockingSummaries {
+	s := &blockingSummaries{
+		pass:  pass,
+		locks: locks,
+		funcs: make(map[string]bool),
+		chans: channelOps(pass.TypesInfo, pass.Files),
+		done:  make(map[*ast.FuncDecl][]blockingOp),
+		lits:  make(map[*ast.BlockStmt]bool),
+	}
+	for _, name := range strings.Split(blockingFuncs, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			s.funcs[name] = true
+		}
+	}
+	for _, scc := range locks.order {
+		for changed := true; changed; {
+			changed = false
+			for _, funcDec := range scc {
+				if ops := s.summarize(funcDec.Body); !sameKeys(ops, s.done[funcDec], blockingOp.key) {
+					changed = true
+					s.done[funcDec] = ops
+				}
+			}
+		}
+	}
+	return s
+}
+
+// summarize returns the blocking operations body runs in the goroutine running it, following the same calls and
+// function literals as the lock summaries
+func (s *blockingSummaries) summarize(body *ast.BlockStmt) (ops []blockingOp) {
+	s.locks.walk(body, func(node ast.Node, c *callInfo) {
+		if c != nil {
+			ops = addBlockingOps(ops, s.callOps(c)...)
+		} else if name := s.chans[node]; name != "" {
+			ops = addBlockingOps(ops, blockingOp{Name: name, Stack: []callFrame{newFrame(name, node.Pos())}})
+		}
+	})
+	return ops
+}
+
+// litOps returns the blocking operations of the function literal body called at call, prefixed with a frame for the call
+func (s *blockingSummaries) litOps(body *ast.BlockStmt, name string, call *ast.CallExpr) (ops []blockingOp) {
+	if s.lits[body] {
+		return nil
+	}
+	s.lits[body] = true
+	defer delete(s.lits, body)
+	frame := newFrame(name, call.Pos())
+	for _, op := range s.summarize(body) { // a function literal shares the variables of the caller
+		op.Stack = append([]callFrame{frame}, op.Stack...)
+		ops = addBlockingOps(ops, op)
+	}
+	return ops
+}
+
+// callOps returns the blocking operations of the functions c may call: the call itself if it calls one of the blocking
+// functions, or the operations of a local function literal or of the declarations it calls otherwise
+func (s *blockingSummaries) callOps(c *callInfo) (ops []blockingOp) {
+	if isLockMethod(c.id) {
+		return nil
+	}
+	if f, ok := c.obj.(*types.Func); ok && s.funcs[f.Origin().FullName()] {
+		name := f.Origin().FullName()
+		op := blockingOp{Name: name, Stack: []callFrame{newFrame(name, c.call.Pos())}}
+		if name == condWait {
+			if locker, ok := s.condLocker(c); ok {
+				op.locker = &locker
+			}
+		}
+		return []blockingOp{op}
+	}
+	if block := s.locks.funcLitBlock(c); block != nil {
+		return s.litOps(block, c.name(), c.call)
+	}
+	for _, callee := range s.locks.callTargets(c) {
+		frame := newFrame(callee.name(), c.call.Pos())
+		for _, op := range s.calleeOps(callee) {
+			op.Stack = append([]callFrame{frame}, op.Stack...)
+			ops = addBlockingOps(ops, op)
+		}
+	}
+	return ops
+}
+
+// calleeOps returns the blocking operations of the function called by c, looking in the current package before imported
+// facts. The Lockers released by its cond waits are rooted at the variables of the calling function, and dropped if
+// they cannot be.
+func (s *blockingSummaries) calleeOps(c *callInfo) (ops []blockingOp) {
+	if c.obj.Pkg() != s.pass.Pkg {
+		var fact blockingFact
+		if !s.pass.ImportObjectFact(c.obj, &fact) {
+			return nil
+		}
+		for _, op := range fact.Ops {
+			imported := blockingOp{Name: op.Name, Stack: op.Stack}
+			if op.Locker != nil {
+				if locker, ok := s.locks.importedPath(c, *op.Locker); ok {
+					imported.locker = &locker
+				}
+			}
+			ops = append(ops, imported)
+		}
+		return ops
+	}
+	funcDec := s.locks.decls.of(c.obj)
+	if funcDec == nil {
+		return nil
+	}
+	for _, op := range s.done[funcDec] {
+		if op.locker != nil {
+			if locker, ok := s.locks.translate(c, funcDec, *op.locker); ok {
+				op.locker = &locker
+			} else {
+				op.locker = nil
+			}
+		}
+		ops = append(ops, op)
+	}
+	return ops
+}
+
+// condLocker returns the access path of the Locker the (*sync.Cond).Wait call c releases while it waits, ending with
+// "Wait", if it is known: the Locker a cond declared with sync.NewCond was created with, the Locker every cond stored
+// in the field the cond is read from was created with, or the L field of the cond it is read from otherwise
+func (s *blockingSummaries) condLocker(c *callInfo) (accessPath, bool) {
+	sel, ok := ast.Unparen(c.selector()).(*ast.SelectorExpr)
+	if !ok {
+		return accessPath{}, false
+	}
+	switch x := ast.Unparen(sel.X).(type) {
+	case *ast.Ident: // cond := sync.NewCond(&mu)
+		if call, ok := declaredValue(x).(*ast.CallExpr); ok && len(call.Args) == 1 {
+			if f := typeutil.StaticCallee(s.pass.TypesInfo, call); f != nil && f.FullName() == "sync.NewCond" {
+				locker, ok := s.locks.exprPath(call.Args[0])
+				if !ok {
+					return accessPath{}, false
+				}
+				return locker.extend(waitElem), true
+			}
+		}
+	case *ast.SelectorExpr: // s.cond, with s.cond = sync.NewCond(&s.mu)
+		if field, ok := s.pass.TypesInfo.ObjectOf(x.Sel).(*types.Var); ok && len(s.locks.decls.conds[field]) > 0 {
+			return s.fieldLocker(x, s.locks.decls.conds[field])
+		}
+	}
+	cond, ok := s.locks.exprPath(sel.X)
+	if !ok {
+		return accessPath{}, false
+	}
+	locker, ok := cond.selectName("L")
+	if !ok {
+		return accessPath{}, false
+	}
+	return locker.extend(waitElem), true
+}
+
+// waitElem ends the access path of the Locker released by a cond wait, in place of a lock method
+var waitElem = pathElem{name: "Wait", field: -1}
+
+// fieldLocker returns the access path of the Locker released by a wait on the cond selected by sel, ending with "Wait",
+// if every cond in inits was created with a Locker selected from the holder of the field, like s.cond =
+// sync.NewCond(&s.mu), and they all select the same one
+func (s *blockingSummaries) fieldLocker(sel *ast.SelectorExpr, inits []condInit) (accessPath, bool) {
+	var suffix []pathElem
+	for i, init := range inits {
+		holder, ok := s.locks.exprPath(init.holder)
+		if !ok {
+			return accessPath{}, false
+		}
+		locker, ok := s.locks.exprPath(init.locker)
+		if !ok || !locker.hasPrefix(holder) {
+			return accessPath{}, false
+		}
+		elems := locker.elems[len(holder.elems):]
+		if i > 0 && !slices.EqualFunc(suffix, elems, func(e, e2 pathElem) bool { return e.key() == e2.key() }) {
+			return accessPath{}, false
+		}
+		suffix = elems
+	}
+	holder, ok := s.locks.exprPath(sel.X)
+	if !ok {
+		return accessPath{}, false
+	}
+	return holder.extend(suffix...).extend(waitElem), true
+}
+
+// check reports the blocking operations node runs if a lock is held in state, along with the calls leading to them
+func (s *blockingSummaries) check(state lockState, node ast.Node) {
+	if len(state.held) == 0 {
+		return
+	}
+	for _, op := range s.nodeOps(node) {
+		for _, heldPath := range state.held.sorted() {
+			if op.locker != nil && op.locker.aliases(heldPath) { // the wait releases the lock
+				continue
+			}
+			frames := append([]callFrame{newFrame(heldPath.method(), heldPath.pos)}, op.Stack...)
+			reportFrames(s.pass, node.Pos(), fmt.Sprintf("%v: %v", errBlockingHeld, op.Name), frames)
+			break
+		}
+	}
+}
+
+// nodeOps returns the blocking operations of node alone, without the nodes it contains: a channel operation, or the
+// operations of the function node calls, including the function literals called in place
+func (s *blockingSummaries) nodeOps(node ast.Node) (ops []blockingOp) {
+	call, ok := node.(*ast.CallExpr)
+	if !ok {
+		if name := s.chans[node]; name != "" {
+			return []blockingOp{{Name: name, Stack: []callFrame{newFrame(name, node.Pos())}}}
+		}
+		return nil
+	}
+	if lit := invokedLit(call); lit != nil { // func() {...}()
+		return s.litOps(lit.Body, "func literal", call)
+	}
+	c := getCallInfo(s.pass.TypesInfo, call)
+	if c == nil {
+		return nil
+	}
+	ops = s.callOps(c)
+	for _, lit := range s.locks.invokedLits(c) { // sort.Slice(x, func(i, j int) bool {...})
+		ops = addBlockingOps(ops, s.litOps(lit.Body, c.name(), call)...)
+	}
+	return ops
+}
+
+// blockingOpKey identifies a blockingOp by its name and the frame of the operation itself
+type blockingOpKey struct {
+	name string
+	at   callFrame
+}
+
+func (op blockingOp) key() blockingOpKey {
+	return blockingOpKey{op.Name, op.Stack[len(op.Stack)-1]}
+}
+
+// addBlockingOps appends the operations in add to ops, skipping the ones already found at the same position
+func addBlockingOps(ops []blockingOp, add ...blockingOp) []blockingOp {
+	for _, op := range add {
+		found := false
+		for _, op2 := range ops {
+			if op2.key() == op.key() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			ops = append(ops, op)
+		}
+	}
+	return ops
+}
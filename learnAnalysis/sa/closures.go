@@ -0,0 +1,81 @@
+package sa
+
+import (
+	"go/ast"
+	"go/types"
+)
+
+// inPlaceCallers maps the functions known to call the function values passed to them before they return to the indices
+// of those parameters. Function literals passed to them run under the locks held by their caller, unlike function
+// literals that are stored, returned or passed to functions that may call them later.
+var inPlaceCallers = map[string][]int{
+	"sort.Slice":            {1},
+	"sort.SliceStable":      {1},
+	"sort.Search":           {1},
+	"slices.SortFunc":       {1},
+	"slices.SortStableFunc": {1},
+	"slices.IndexFunc":      {1},
+	"slices.ContainsFunc":   {1},
+	"slices.DeleteFunc":     {1},
+	"strings.Map":           {0},
+	"strings.FieldsFunc":    {1},
+	"strings.IndexFunc":     {1},
+	"(*sync.Once).Do":       {0},
+	"(*sync.Map).Range":     {0},
+}
+
+// invokedArgs returns the indices of the func-valued parameters the function called by c calls before returning,
+// either because it is one of the inPlaceCallers or because its summary says so
+func (s *lockSummaries) invokedArgs(c *callInfo) []int {
+	f, ok := c.obj.(*types.Func)
+	if !ok {
+		return nil
+	}
+	if indices, found := inPlaceCallers[f.Origin().FullName()]; found {
+		return indices
+	}
+	return s.invokes[s.decls.of(f)]
+}
+
+// invokedLits returns the function literals passed to the function called by c that it calls before returning
+func (s *lockSummaries) invokedLits(c *callInfo) (lits []*ast.FuncLit) {
+	for _, index := range s.invokedArgs(c) {
+		if lit, ok := ast.Unparen(s.argExpr(c, index)).(*ast.FuncLit); ok {
+			lits = append(lits, lit)
+		}
+	}
+	return lits
+}
+
+// litLocks returns the locks acquired by the function literal lit when it is called at call, which shares the
+// variables of the calling function
+func (s *lockSummaries) litLocks(lit *ast.FuncLit, name string, call *ast.CallExpr) (locks []summaryLock) {
+	if s.lits[lit.Body] {
+		return nil
+	}
+	s.lits[lit.Body] = true
+	defer delete(s.lits, lit.Body)
+	frame := newFrame(name, call.Pos())
+	for _, l := range s.summarize(lit.Body) {
+		l.stack = append([]callFrame{frame}, l.stack...)
+		locks = addSummaryLock(locks, l)
+	}
+	return locks
+}
+
+// invokedLitLocks returns the locks acquired by the function literals passed to the function called by c that it calls
+// before returning
+func (s *lockSummaries) invokedLitLocks(c *callInfo) (locks []summaryLock) {
+	for _, lit := range s.invokedLits(c) {
+		for _, l := range s.litLocks(lit, c.name(), c.call) {
+			locks = addSummaryLock(locks, l)
+		}
+	}
+	return locks
+}
+
+// invokedLit returns the function literal call calls in place, like func() {...}(), or nil
+func invokedLit(call *ast.CallExpr) *ast.FuncLit {
+	lit, _ := ast.Unparen(call.Fun).(*ast.FuncLit)
+	return lit
+}
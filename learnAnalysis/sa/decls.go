@@ -0,0 +1,193 @@
+package sa
+
+import (
+	"errors"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"reflect"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// declsAnalyzer indexes the function declarations of a package once, so every analyzer requiring it can look up the
+// declaration of a called function without walking the package again. The function literals stored in struct fields
+// are indexed along with them, and so are the conds stored in struct fields.
+var declsAnalyzer = &analysis.Analyzer{
+	Name:       "funcdecls",
+	Doc:        "Indexes the function and method declarations of a package by the function they declare",
+	Requires:   []*analysis.Analyzer{inspect.Analyzer},
+	Run:        runDecls,
+	ResultType: reflect.TypeOf((*funcDecls)(nil)),
+}
+
+// funcDecls maps the functions and methods declared in a package to their declarations
+type funcDecls struct {
+	byFunc map[*types.Func]*ast.FuncDecl
+	list   []*ast.FuncDecl // every declaration in source order
+
+	fields map[fieldKey][]*ast.FuncLit // function literals stored in a field of a variable, in source order
+	conds  map[*types.Var][]condInit   // sync.NewCond calls stored in a field, in source order
+
+	impls *implementers // resolves interface method calls, once per package
+}
+
+// condInit is a cond created with sync.NewCond(locker) and stored in a field of holder, like x.cond = sync.NewCond(&x.mu)
+type condInit struct {
+	holder, locker ast.Expr
+}
+
+// fieldKey identifies a func-valued field of the struct held by a variable, like s.f, through a pointer or not
+type fieldKey struct {
+	root  types.Object
+	field *types.Var
+}
+
+func runDecls(pass *analysis.Pass) (interface{}, error) {
+	inspect, ok := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	if !ok {
+		return nil, errors.New("analyzer is not type *inspector.Inspector")
+	}
+	d := newFuncDecls(pass.TypesInfo, inspect)
+	d.impls = newImplementers(pass.Pkg)
+	return d, nil
+}
+
+func newFuncDecls(info *types.Info, inspect *inspector.Inspector) *funcDecls {
+	d := &funcDecls{
+		byFunc: make(map[*types.Func]*ast.FuncDecl),
+		fields: make(map[fieldKey][]*ast.FuncLit),
+		conds:  make(map[*types.Var][]condInit),
+	}
+	nodeFilter := []ast.Node{
+		(*ast.FuncDecl)(nil),
+		(*ast.AssignStmt)(nil),
+		(*ast.ValueSpec)(nil),
+	}
+	inspect.Preorder(nodeFilter, func(node ast.Node) {
+		switch stmt := node.(type) {
+		case *ast.FuncDecl:
+			d.list = append(d.list, stmt)
+			if f, ok := info.Defs[stmt.Name].(*types.Func); ok {
+				d.byFunc[f] = stmt
+			}
+		case *ast.AssignStmt:
+			if len(stmt.Lhs) != len(stmt.Rhs) { // only deals with simple assignments
+				return
+			}
+			for i, lhs := range stmt.Lhs {
+				switch lhs := ast.Unparen(lhs).(type) {
+				case *ast.Ident: // s := &T{f: func() {...}}
+					d.addCompositeLit(info, info.ObjectOf(lhs), stmt.Rhs[i])
+				case *ast.SelectorExpr: // s.f = func() {...}
+					d.addCond(info, lhs, stmt.Rhs[i])
+					lit, ok := ast.Unparen(stmt.Rhs[i]).(*ast.FuncLit)
+					if root := rootIdent(lhs.X); ok && root != nil {
+						if field, ok := info.ObjectOf(lhs.Sel).(*types.Var); ok && field.IsField() {
+							d.addField(fieldKey{info.ObjectOf(root), field}, lit)
+						}
+					}
+				}
+			}
+		case *ast.ValueSpec:
+			if len(stmt.Names) != len(stmt.Values) {
+				return
+			}
+			for i, name := range stmt.Names {
+				d.addCompositeLit(info, info.ObjectOf(name), stmt.Values[i])
+			}
+		}
+	})
+	return d
+}
+
+// addCompositeLit indexes the function literals stored in the fields of the composite literal value, if value is
+// a struct literal or its address, as stored in the variable root
+func (d *funcDecls) addCompositeLit(info *types.Info, root types.Object, value ast.Expr) {
+	value = ast.Unparen(value)
+	if addr, ok := value.(*ast.UnaryExpr); ok && addr.Op == token.AND {
+		value = ast.Unparen(addr.X)
+	}
+	lit, ok := value.(*ast.CompositeLit)
+	if !ok || root == nil {
+		return
+	}
+	s, ok := deref(info.TypeOf(lit)).Underlying().(*types.Struct)
+	if !ok {
+		return
+	}
+	for i, elt := range lit.Elts {
+		field, value := (*types.Var)(nil), elt
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			if key, ok := kv.Key.(*ast.Ident); ok {
+				field, _ = info.ObjectOf(key).(*types.Var)
+			}
+			value = kv.Value
+		} else if i < s.NumFields() {
+			field = s.Field(i)
+		}
+		if fn, ok := ast.Unparen(value).(*ast.FuncLit); ok && field != nil {
+			d.addField(fieldKey{root, field}, fn)
+		}
+	}
+}
+
+// addCond indexes value if it creates a cond stored in the field selected by sel, like x.cond = sync.NewCond(&x.mu)
+func (d *funcDecls) addCond(info *types.Info, sel *ast.SelectorExpr, value ast.Expr) {
+	call, ok := ast.Unparen(value).(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		return
+	}
+	if f := typeutil.StaticCallee(info, call); f == nil || f.FullName() != "sync.NewCond" {
+		return
+	}
+	if field, ok := info.ObjectOf(sel.Sel).(*types.Var); ok && field.IsField() {
+		d.conds[field] = append(d.conds[field], condInit{holder: sel.X, locker: call.Args[0]})
+	}
+}
+
+func (d *funcDecls) addField(key fieldKey, lit *ast.FuncLit) {
+	d.fields[key] = append(d.fields[key], lit)
+}
+
+// fieldLit returns the function literal stored in the field selected by sel, or nil unless exactly one function
+// literal is stored in it
+func (d *funcDecls) fieldLit(info *types.Info, sel *ast.SelectorExpr) *ast.FuncLit {
+	selection := info.Selections[sel]
+	root := rootIdent(sel.X)
+	if selection == nil || selection.Kind() != types.FieldVal || root == nil {
+		return nil
+	}
+	field, ok := selection.Obj().(*types.Var)
+	if !ok {
+		return nil
+	}
+	if lits := d.fields[fieldKey{info.ObjectOf(root), field}]; len(lits) == 1 {
+		return lits[0]
+	}
+	return nil
+}
+
+// rootIdent returns the variable e refers to, skipping parentheses and pointer indirections, or nil if e is not a variable
+func rootIdent(e ast.Expr) *ast.Ident {
+	switch e := ast.Unparen(e).(type) {
+	case *ast.Ident:
+		return e
+	case *ast.StarExpr:
+		return rootIdent(e.X)
+	}
+	return nil
+}
+
+// of returns the declaration of the function or method obj, or nil if it is not declared in the package. Instances of
+// generic functions and methods of instantiated types resolve to the generic declaration.
+func (d *funcDecls) of(obj types.Object) *ast.FuncDecl {
+	f, ok := obj.(*types.Func)
+	if !ok {
+		return nil
+	}
+	return d.byFunc[f.Origin()]
+}
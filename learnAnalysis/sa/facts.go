@@ -0,0 +1,99 @@
+package sa
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// lockFact is exported for every exported function or method that may acquire a lock when it is called.
+// Only the declarations of the package being analyzed are indexed (see funcDecls), so importing packages
+// use this fact to follow nested RLocks across package boundaries.
+type lockFact struct {
+	Locks []lockAcquisition
+}
+
+// lockAcquisition describes a single lock acquired by a function, either directly or through the functions it calls.
+type lockAcquisition struct {
+	Global string      // package path and name of the package-level variable the lock is rooted at, if any
+	Param  int         // otherwise, the index of the parameter the lock is rooted at, or -1 for the receiver
+	Path   []string    // selector names and indices following the root, ending with the lock method (see factPath)
+	Stack  []callFrame // calls leading to the acquisition, ending with the lock call itself
+}
+
+// callFrame is a single call in the stack of a lockAcquisition. Frames found in the package being analyzed keep their
+// token.Pos, and their position is only stored as a string once they are exported (see exportFrames), so the fact can
+// be serialized.
+type callFrame struct {
+	Name string
+	Pos  string
+	pos  token.Pos
+}
+
+func (*lockFact) AFact() {}
+
+func (f *lockFact) String() string {
+	locks := make([]string, len(f.Locks))
+	for i, l := range f.Locks {
+		locks[i] = l.String()
+	}
+	return "acquires " + strings.Join(locks, ", ")
+}
+
+func (l lockAcquisition) String() string {
+	root := "recv"
+	if l.Global != "" {
+		root = l.Global
+	} else if l.Param >= 0 {
+		root = fmt.Sprintf("param%d", l.Param)
+	}
+	str := root + "." + strings.Join(l.Path, ".")
+	if len(l.Stack) > 1 {
+		via := make([]string, len(l.Stack)-1)
+		for i, frame := range l.Stack[:len(l.Stack)-1] {
+			via[i] = frame.Name
+		}
+		str += " via " + strings.Join(via, " > ")
+	}
+	return str
+}
+
+// exportLockFacts exports a lockFact for every exported function declared in the package that may acquire a lock.
+// Unexported functions cannot be called from other packages, and any lock they acquire is already part of the
+// fact of the exported function calling them.
+func exportLockFacts(pass *analysis.Pass, summaries *lockSummaries) {
+	for _, funcDec := range summaries.decls.list {
+		if !funcDec.Name.IsExported() {
+			continue
+		}
+		var locks []lockAcquisition
+		for _, l := range summaries.done[funcDec] {
+			if lock, ok := summaries.acquisition(funcDec, l); ok {
+				lock.Stack = exportFrames(pass.Fset, lock.Stack)
+				locks = append(locks, lock)
+			}
+		}
+		if len(locks) > 0 {
+			pass.ExportObjectFact(pass.TypesInfo.ObjectOf(funcDec.Name), &lockFact{Locks: locks})
+		}
+	}
+}
+
+// acquisition returns the lockAcquisition of the lock l found in the summary of funcDec, or false if l is rooted at a
+// local variable, which callers cannot refer to
+func (s *lockSummaries) acquisition(funcDec *ast.FuncDecl, l summaryLock) (lockAcquisition, bool) {
+	lock := lockAcquisition{Path: factPath(s.pass.TypesInfo, funcDec, l.accessPath), Stack: l.stack}
+	if isGlobal(l.root) {
+		lock.Global = rootKey(l.root)
+	} else {
+		index, ok := paramIndex(s.pass.TypesInfo, funcDec, l.root)
+		if !ok {
+			return lockAcquisition{}, false
+		}
+		lock.Param = index
+	}
+	return lock, true
+}
@@ -0,0 +1,94 @@
+package sa
+
+import (
+	"go/ast"
+	"go/token"
+
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// goEvaluated returns the expressions of a go statement evaluated by the goroutine running it: the arguments and the
+// function value, unless it is a function literal. The call itself runs in the new goroutine, which starts out without
+// any locks held, so neither it nor the function literal it calls is followed.
+func goEvaluated(stmt *ast.GoStmt) (exprs []ast.Expr) {
+	if _, isLit := ast.Unparen(stmt.Call.Fun).(*ast.FuncLit); !isLit {
+		exprs = append(exprs, stmt.Call.Fun)
+	}
+	return append(exprs, stmt.Call.Args...)
+}
+
+// goLocks returns the locks acquired by the goroutine started by stmt, rooted at the variables of the function
+// starting it
+func (s *lockSummaries) goLocks(stmt *ast.GoStmt) (locks []summaryLock) {
+	if lit, ok := ast.Unparen(stmt.Call.Fun).(*ast.FuncLit); ok { // a function literal shares the variables of the caller
+		frame := newFrame("func literal", lit.Pos())
+		for _, l := range s.summarize(lit.Body) {
+			l.stack = append([]callFrame{frame}, l.stack...)
+			locks = append(locks, l)
+		}
+		return locks
+	}
+	if c := getCallInfo(s.pass.TypesInfo, stmt.Call); c != nil {
+		return s.callLocks(c)
+	}
+	return nil
+}
+
+// spawn is a goroutine started while a lock it acquires was held
+type spawn struct {
+	pos   token.Pos   // the go statement
+	stack []callFrame // calls leading from the goroutine to the acquisition
+}
+
+// spawnConflicts returns the locks in held the goroutine started by stmt acquires in a way that blocks until they are
+// released, that is, unless both the held lock and the lock of the goroutine are read locks. The function running stmt
+// cannot wait for the new goroutine while it holds them.
+func (f *lockFlow) spawnConflicts(held lockset, stmt *ast.GoStmt) (conflicts []accessPath) {
+	locks := f.summaries.goLocks(stmt)
+	for _, heldPath := range held.sorted() {
+		for _, l := range locks {
+			if !l.aliases(heldPath) || (heldPath.method() == "RLock" && l.method() == "RLock") {
+				continue
+			}
+			if _, found := f.spawns[heldPath.key]; !found {
+				f.spawns[heldPath.key] = spawn{pos: stmt.Pos(), stack: l.stack}
+			}
+			conflicts = append(conflicts, heldPath)
+			break
+		}
+	}
+	return conflicts
+}
+
+// isWait returns true if e waits for another goroutine: a channel receive or a call to sync.WaitGroup.Wait
+func (f *lockFlow) isWait(e ast.Expr) (name string, ok bool) {
+	switch e := e.(type) {
+	case *ast.UnaryExpr:
+		return "<-", e.Op == token.ARROW
+	case *ast.CallExpr:
+		if fn := typeutil.StaticCallee(f.pass.TypesInfo, e); fn != nil && fn.FullName() == "(*sync.WaitGroup).Wait" {
+			return fn.FullName(), true
+		}
+	}
+	return "", false
+}
+
+// checkWait reports wait if it waits while holding a lock acquired by a goroutine started since it was acquired, which
+// cannot acquire it until the wait is over
+func (f *lockFlow) checkWait(state lockState, wait ast.Expr) {
+	name, _ := f.isWait(wait)
+	for _, lock := range state.spawned.sorted() {
+		held, isHeld := state.held.find(lock, lock.method())
+		s, found := f.spawns[lock.key]
+		if !isHeld || !found {
+			continue
+		}
+		frames := []callFrame{
+			newFrame(held.method(), held.pos),
+			newFrame("go", s.pos),
+		}
+		frames = append(frames, s.stack...)
+		reportFrames(f.pass, wait.Pos(), errWaitSpawned.Error(), append(frames, newFrame(name, wait.Pos())))
+		return
+	}
+}
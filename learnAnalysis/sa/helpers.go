@@ -0,0 +1,319 @@
+package sa
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+)
+
+// lockHelper is the effect a function has on the locks of its caller, for functions wrapping lock calls like
+//
+//	func (s *S) lockRead() { s.mu.RLock() }
+//	func (s *S) rlock() func() { s.mu.RLock(); return s.mu.RUnlock }
+//
+// Only the lock events at the end of a function without early returns are taken into account, so a function doing
+// anything after acquiring a lock is not mistaken for a helper.
+type lockHelper struct {
+	acquires []summaryLock // locks the function returns holding, ending with the lock method
+	releases []summaryLock // locks held by the caller that the function releases, ending with the unlock method
+	returns  []summaryLock // locks released by calling the function returned by the function, ending with the unlock method
+}
+
+func (h lockHelper) equal(h2 lockHelper) bool {
+	return sameLocks(h.acquires, h2.acquires) && sameLocks(h.releases, h2.releases) && sameLocks(h.returns, h2.returns)
+}
+
+// lockHelperOf returns the lockHelper of funcDec with the helpers computed so far
+func (s *lockSummaries) lockHelperOf(funcDec *ast.FuncDecl) (h lockHelper) {
+	stmts := funcDec.Body.List
+	var ret *ast.ReturnStmt
+	if n := len(stmts); n > 0 {
+		if r, ok := stmts[n-1].(*ast.ReturnStmt); ok {
+			ret, stmts = r, stmts[:n-1]
+		}
+	}
+	if returnsEarly(funcDec.Body, ret) {
+		return lockHelper{}
+	}
+	var events []summaryLock
+	if ret != nil && len(ret.Results) == 1 { // return s.rlock()
+		if call, ok := ast.Unparen(ret.Results[0]).(*ast.CallExpr); ok {
+			events = s.lockEvents(call)
+		}
+		h.returns = s.closureReleases(ret.Results[0])
+	}
+	// a function returning anything but the function releasing its locks works under them, like return s.data[k]
+	holds := ret == nil || len(ret.Results) == 0 || len(events) > 0 || len(h.returns) > 0
+	start := len(stmts)
+	for ; start > 0; start-- {
+		call := exprCall(stmts[start-1])
+		if call == nil {
+			break
+		}
+		callEvents := s.lockEvents(call)
+		if len(callEvents) == 0 {
+			break
+		}
+		events = append(callEvents, events...)
+	}
+	before := s.summarize(&ast.BlockStmt{List: stmts[:start]})
+	for _, l := range events {
+		switch l.method() {
+		case "RLock", "Lock":
+			if holds {
+				h.acquires = addSummaryLock(h.acquires, l)
+			}
+		case "RUnlock", "Unlock":
+			if acquires, ok := removeSummaryLock(h.acquires, l); ok {
+				h.acquires = acquires
+				continue
+			}
+			if _, ok := removeSummaryLock(before, l); !ok { // released without being acquired by funcDec itself
+				h.releases = addSummaryLock(h.releases, l)
+			}
+		}
+	}
+	for _, l := range s.deferredReleases(funcDec.Body) { // defer s.mu.RUnlock() releases the lock before returning
+		h.acquires, _ = removeSummaryLock(h.acquires, l)
+	}
+	visible := func(l summaryLock) bool {
+		_, ok := paramIndex(s.pass.TypesInfo, funcDec, l.root)
+		return ok || isGlobal(l.root)
+	}
+	h.acquires = filterSummaryLocks(h.acquires, visible)
+	h.releases = filterSummaryLocks(h.releases, visible)
+	h.returns = filterSummaryLocks(h.returns, visible)
+	return h
+}
+
+// returnsEarly returns true if body has a return statement other than ret, not counting the ones of function literals
+func returnsEarly(body *ast.BlockStmt, ret *ast.ReturnStmt) (early bool) {
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch stmt := node.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.ReturnStmt:
+			early = early || stmt != ret
+		}
+		return !early
+	})
+	return early
+}
+
+// deferredReleases returns the locks released by the calls body defers
+func (s *lockSummaries) deferredReleases(body *ast.BlockStmt) (releases []summaryLock) {
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch stmt := node.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.DeferStmt:
+			if lit, ok := ast.Unparen(stmt.Call.Fun).(*ast.FuncLit); ok {
+				releases = append(releases, s.closureReleases(lit)...)
+				return false
+			}
+			for _, l := range s.lockEvents(stmt.Call) {
+				if _, isUnlock := releasedMethods[l.method()]; isUnlock {
+					releases = append(releases, l)
+				}
+			}
+			return false
+		}
+		return true
+	})
+	return releases
+}
+
+// exprCall returns the call stmt consists of, or nil if it is not a call statement
+func exprCall(stmt ast.Stmt) *ast.CallExpr {
+	if expr, ok := stmt.(*ast.ExprStmt); ok {
+		call, _ := ast.Unparen(expr.X).(*ast.CallExpr)
+		return call
+	}
+	return nil
+}
+
+// lockEvents returns the locks acquired and released by call in the order it does so, rooted at the variables of the
+// calling function. Besides lock and unlock calls, these are the calls to helpers (see lockHelper) and to the
+// functions returned by them.
+func (s *lockSummaries) lockEvents(call *ast.CallExpr) (events []summaryLock) {
+	if inner, ok := ast.Unparen(call.Fun).(*ast.CallExpr); ok { // s.rlock()()
+		return s.returnedReleases(inner)
+	}
+	c := getCallInfo(s.pass.TypesInfo, call)
+	if c == nil {
+		return nil
+	}
+	if isLockMethod(c.id) {
+		if p, ok := s.callPath(call); ok {
+			return []summaryLock{{p, []callFrame{newFrame(c.id, call.Pos())}}}
+		}
+		return nil
+	}
+	if _, isVar := c.obj.(*types.Var); isVar { // unlock := s.rlock(); unlock()
+		if id, ok := ast.Unparen(call.Fun).(*ast.Ident); ok {
+			if value, ok := declaredValue(id).(*ast.CallExpr); ok {
+				return s.returnedReleases(value)
+			}
+		}
+		return nil
+	}
+	for _, callee := range s.callTargets(c) {
+		funcDec := s.decls.of(callee.obj)
+		if funcDec == nil || funcDec.Body == nil {
+			continue
+		}
+		h := s.helpers[funcDec.Body]
+		frame := newFrame(callee.name(), call.Pos())
+		for _, l := range append(h.releases[:len(h.releases):len(h.releases)], h.acquires...) {
+			if p, ok := s.translate(callee, funcDec, l.accessPath); ok {
+				l = summaryLock{p, append([]callFrame{frame}, l.stack...)}
+				events = append(events, l)
+			}
+		}
+	}
+	return events
+}
+
+// returnedReleases returns the locks released by calling the function returned by call, rooted at the variables of
+// the calling function
+func (s *lockSummaries) returnedReleases(call *ast.CallExpr) (releases []summaryLock) {
+	c := getCallInfo(s.pass.TypesInfo, call)
+	if c == nil {
+		return nil
+	}
+	for _, callee := range s.callTargets(c) {
+		funcDec := s.decls.of(callee.obj)
+		if funcDec == nil || funcDec.Body == nil {
+			continue
+		}
+		frame := newFrame(callee.name(), call.Pos())
+		for _, l := range s.helpers[funcDec.Body].returns {
+			if p, ok := s.translate(callee, funcDec, l.accessPath); ok {
+				l = summaryLock{p, append([]callFrame{frame}, l.stack...)}
+				releases = append(releases, l)
+			}
+		}
+	}
+	return releases
+}
+
+// closureReleases returns the locks released by calling the function value e: an unlock method value like
+// s.mu.RUnlock, a function literal calling unlock methods, or the function returned by a helper
+func (s *lockSummaries) closureReleases(e ast.Expr) (releases []summaryLock) {
+	switch e := ast.Unparen(e).(type) {
+	case *ast.SelectorExpr:
+		if _, isUnlock := releasedMethods[e.Sel.Name]; !isUnlock {
+			return nil
+		}
+		if selection := s.pass.TypesInfo.Selections[e]; selection == nil || selection.Kind() != types.MethodVal {
+			return nil
+		}
+		if p, ok := s.exprPath(e); ok {
+			return []summaryLock{{p, []callFrame{newFrame(e.Sel.Name, e.Pos())}}}
+		}
+	case *ast.FuncLit:
+		for _, stmt := range e.Body.List {
+			if call := exprCall(stmt); call != nil {
+				for _, l := range s.lockEvents(call) {
+					if _, isUnlock := releasedMethods[l.method()]; isUnlock {
+						releases = addSummaryLock(releases, l)
+					}
+				}
+			}
+		}
+	case *ast.CallExpr:
+		return s.returnedReleases(e)
+	}
+	return releases
+}
+
+// declaredValue returns the value the variable id was declared with, or nil. Like identifyFuncLitBlock, later
+// assignments to the variable are not followed.
+func declaredValue(id *ast.Ident) ast.Expr {
+	if id.Obj == nil {
+		return nil
+	}
+	switch objDecl := id.Obj.Decl.(type) {
+	case *ast.ValueSpec:
+		if i := findIdentIndex(id, objDecl.Names); i != -1 && len(objDecl.Names) == len(objDecl.Values) {
+			return ast.Unparen(objDecl.Values[i])
+		}
+	case *ast.AssignStmt:
+		if i := findIdentIndexFromExpr(id, objDecl.Lhs); i != -1 && len(objDecl.Lhs) == len(objDecl.Rhs) {
+			return ast.Unparen(objDecl.Rhs[i])
+		}
+	}
+	return nil
+}
+
+// removeSummaryLock returns locks without the lock released by the unlock path l, and true if it was found
+func removeSummaryLock(locks []summaryLock, l summaryLock) ([]summaryLock, bool) {
+	for i, found := range locks {
+		if found.sameMutex(l.accessPath) && unlockMethods[found.method()] == l.method() {
+			return append(locks[:i:i], locks[i+1:]...), true
+		}
+	}
+	return locks, false
+}
+
+func filterSummaryLocks(locks []summaryLock, keep func(summaryLock) bool) (ret []summaryLock) {
+	for _, l := range locks {
+		if keep(l) {
+			ret = append(ret, l)
+		}
+	}
+	return ret
+}
+
+// callEvents returns the access paths of the locks released and acquired by call through helpers, so lockFlow can
+// treat them like unlock and lock calls made at call
+func (s *lockSummaries) callEvents(call *ast.CallExpr) (releases, acquires []accessPath) {
+	if c := getCallInfo(s.pass.TypesInfo, call); c != nil && isLockMethod(c.id) {
+		return nil, nil
+	}
+	for _, l := range s.lockEvents(call) {
+		p := l.at(call.Pos())
+		if _, isUnlock := releasedMethods[l.method()]; isUnlock {
+			releases = append(releases, p)
+		} else {
+			acquires = append(acquires, p)
+		}
+	}
+	return releases, acquires
+}
+
+// lookupGlobal returns the package-level variable named by key, the package path and name of a variable declared in
+// the package being analyzed or in one of the packages it depends on, directly or not
+func (s *lockSummaries) lookupGlobal(key string) types.Object {
+	seen := make(map[*types.Package]bool)
+	var lookup func(pkg *types.Package) types.Object
+	lookup = func(pkg *types.Package) types.Object {
+		if seen[pkg] {
+			return nil
+		}
+		seen[pkg] = true
+		if name, ok := strings.CutPrefix(key, pkg.Path()+"."); ok {
+			if v, ok := pkg.Scope().Lookup(name).(*types.Var); ok {
+				return v
+			}
+		}
+		for _, imported := range pkg.Imports() {
+			if v := lookup(imported); v != nil {
+				return v
+			}
+		}
+		return nil
+	}
+	return lookup(s.pass.Pkg)
+}
+
+// returnsHolding returns true if the function the lockFlow is analyzing is a helper returning with the lock acquired
+// by lock held
+func (f *lockFlow) returnsHolding(lock accessPath) bool {
+	for _, l := range f.summaries.helpers[f.body].acquires {
+		if l.equal(lock) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,278 @@
+package sa
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+)
+
+// mayAlias makes locks selected with different indices from the same slice, array or map count as the same lock, so
+// shards[i].mu.Lock() nested in shards[j].mu.Lock() is reported as well
+var mayAlias bool
+
+// unknownIndex is the path element of an index that cannot be written in the function the path is rooted in, like a
+// local variable of a called function. It selects the same lock as no other index unless mayAlias is set.
+const unknownIndex = "[?]"
+
+// unknownElem is the index element of an unknown index (see unknownIndex)
+var unknownElem = pathElem{name: unknownIndex, field: -1, index: &pathIndex{key: "?"}}
+
+// pathIndex is the index an index element of an accessPath selects an element with
+type pathIndex struct {
+	value constant.Value // the value of a constant index
+	vars  []*types.Var   // otherwise, the variables the index expression refers to
+	key   string         // the value of a constant index, or the index expression with the declarations of its variables
+}
+
+// newIndexElem returns the path element selecting the element of a slice, array or map at index. Constant indices are
+// identified by their value, and other indices by the expression along with the variables it refers to, so a variable
+// shadowing another one of the same name makes a different index. The locks selected with a variable have to be
+// forgotten when it is assigned (see lockset.forget).
+func newIndexElem(info *types.Info, index ast.Expr) pathElem {
+	if tv, ok := info.Types[index]; ok && tv.Value != nil {
+		return constantElem(tv.Value)
+	}
+	i := &pathIndex{}
+	var decls []string
+	ast.Inspect(index, func(node ast.Node) bool {
+		if id, ok := node.(*ast.Ident); ok {
+			if v, ok := info.Uses[id].(*types.Var); ok && !v.IsField() {
+				i.vars = append(i.vars, v)
+				decls = append(decls, strconv.Itoa(int(v.Pos())))
+			}
+		}
+		return true
+	})
+	expr := types.ExprString(index)
+	i.key = expr + "@" + strings.Join(decls, ",")
+	return pathElem{name: "[" + expr + "]", field: -1, index: i}
+}
+
+// constantElem returns the path element selecting the element at the constant index value
+func constantElem(value constant.Value) pathElem {
+	key := value.ExactString()
+	return pathElem{name: "[" + key + "]", field: -1, index: &pathIndex{value: value, key: key}}
+}
+
+// isIndexExpr returns true if e selects an element of a slice, array or map, rather than instantiating a generic
+// function or type
+func isIndexExpr(info *types.Info, e *ast.IndexExpr) bool {
+	t := info.TypeOf(e.X)
+	if t == nil {
+		return false
+	}
+	switch deref(t).Underlying().(type) {
+	case *types.Slice, *types.Array, *types.Map:
+		return true
+	}
+	return false
+}
+
+// indexElem returns the type of the elements of t selected by an index, or nil if t cannot be indexed
+func indexElem(t types.Type) types.Type {
+	switch t := deref(t).Underlying().(type) {
+	case *types.Slice:
+		return t.Elem()
+	case *types.Array:
+		return t.Elem()
+	case *types.Map:
+		return t.Elem()
+	}
+	return nil
+}
+
+// isIndex returns true if the path element elem selects an element by its index
+func isIndex(elem string) bool {
+	return strings.HasPrefix(elem, "[")
+}
+
+// sameIndex returns true if the index elements a and b may select the same element
+func sameIndex(a, b string) bool {
+	return mayAlias || (a == b && a != unknownIndex)
+}
+
+// samePathElems returns true if the path elements of path and path2 select the same lock, comparing indices with
+// sameIndex
+func samePathElems(path, path2 []string) bool {
+	if len(path) != len(path2) {
+		return false
+	}
+	for i := range path {
+		if isIndex(path[i]) && isIndex(path2[i]) {
+			if !sameIndex(path[i], path2[i]) {
+				return false
+			}
+		} else if path[i] != path2[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// aliases returns true if p and p2 may lock the same mutex, regardless of their lock methods. Unlike sameMutex, an
+// unknown index selects the same lock as no other index, and every index selects the same lock if mayAlias is set.
+func (p accessPath) aliases(p2 accessPath) bool {
+	if len(p.elems) != len(p2.elems) || rootKey(p.root) != rootKey(p2.root) {
+		return false
+	}
+	for i := range len(p.elems) - 1 {
+		e, e2 := p.elems[i], p2.elems[i]
+		if e.index != nil && e2.index != nil {
+			if !sameIndex(e.key(), e2.key()) {
+				return false
+			}
+		} else if e.key() != e2.key() {
+			return false
+		}
+	}
+	return true
+}
+
+// assignedVar returns the variable an assignment to e changes, or nil if there is none
+func assignedVar(info *types.Info, e ast.Expr) types.Object {
+	switch e := ast.Unparen(e).(type) {
+	case *ast.Ident:
+		return info.ObjectOf(e)
+	case *ast.SelectorExpr:
+		if _, ok := info.Selections[e]; !ok { // a qualified identifier, like iTypes.Shared
+			return info.ObjectOf(e.Sel)
+		}
+		return assignedVar(info, e.X)
+	case *ast.IndexExpr:
+		return assignedVar(info, e.X)
+	case *ast.StarExpr:
+		return assignedVar(info, e.X)
+	}
+	return nil
+}
+
+// assignedVars returns the variables assigned anywhere in body, including by the function literals it declares
+func assignedVars(info *types.Info, body ast.Node) map[types.Object]bool {
+	vars := make(map[types.Object]bool)
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch stmt := node.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range stmt.Lhs {
+				vars[assignedVar(info, lhs)] = true
+			}
+		case *ast.IncDecStmt:
+			vars[assignedVar(info, stmt.X)] = true
+		case *ast.RangeStmt:
+			for _, e := range []ast.Expr{stmt.Key, stmt.Value} {
+				if e != nil {
+					vars[assignedVar(info, e)] = true
+				}
+			}
+		}
+		return true
+	})
+	return vars
+}
+
+// paramElem returns the index of the parameter of funcDec the index element e consists of, or false if e is not
+// a parameter, or if funcDec assigns it, so callers cannot write it
+func paramElem(info *types.Info, funcDec *ast.FuncDecl, e pathElem) (int, bool) {
+	if len(e.index.vars) != 1 || e.name != "["+e.index.vars[0].Name()+"]" {
+		return 0, false
+	}
+	index, ok := paramIndex(info, funcDec, e.index.vars[0])
+	if !ok || index < 0 || assignedVars(info, funcDec.Body)[e.index.vars[0]] {
+		return 0, false
+	}
+	return index, true
+}
+
+// translateIndex returns the index element e of a path found in funcDec, called by c, selecting the same element in
+// the calling function: constants are kept, parameters are replaced with the matching argument, and the indices the
+// caller cannot write become unknown
+func (s *lockSummaries) translateIndex(c *callInfo, funcDec *ast.FuncDecl, e pathElem) pathElem {
+	if e.index.value != nil {
+		return e
+	}
+	if index, ok := paramElem(s.pass.TypesInfo, funcDec, e); ok {
+		if arg := s.argExpr(c, index); arg != nil {
+			return newIndexElem(s.pass.TypesInfo, arg)
+		}
+	}
+	return unknownElem
+}
+
+// factPath returns the names of the path elements of p, found in funcDec, for a lockFact. Indices are written as
+// their constant value, as paramN for the parameter funcDec does not assign at index N, and as unknown otherwise.
+func factPath(info *types.Info, funcDec *ast.FuncDecl, p accessPath) []string {
+	names := p.names()
+	for i, e := range p.elems {
+		if e.index == nil || e.index.value != nil {
+			continue
+		}
+		names[i] = unknownIndex
+		if index, ok := paramElem(info, funcDec, e); ok {
+			names[i] = fmt.Sprintf("[param%d]", index)
+		}
+	}
+	return names
+}
+
+// factParam returns the index of the parameter the index element elem of a lockFact path refers to, or false if it
+// does not refer to one (see factPath)
+func factParam(elem string) (int, bool) {
+	digits, ok := strings.CutPrefix(strings.TrimSuffix(elem, "]"), "[param")
+	if !ok {
+		return 0, false
+	}
+	index, err := strconv.Atoi(digits)
+	return index, err == nil
+}
+
+// factIndex returns the index element elem of the path of a lockFact of the function called by c, rooted at the
+// variables of the calling function (see factPath)
+func (s *lockSummaries) factIndex(c *callInfo, elem string) pathElem {
+	if index, ok := factParam(elem); ok {
+		if arg := s.argExpr(c, index); arg != nil {
+			return newIndexElem(s.pass.TypesInfo, arg)
+		}
+		return unknownElem
+	}
+	if value := factConstant(elem); value != nil {
+		return constantElem(value)
+	}
+	return unknownElem
+}
+
+// factConstant returns the constant value the index element elem of a lockFact path is written with, or nil
+func factConstant(elem string) constant.Value {
+	lit := strings.TrimSuffix(strings.TrimPrefix(elem, "["), "]")
+	switch lit {
+	case "true", "false":
+		return constant.MakeBool(lit == "true")
+	}
+	lit, neg := strings.CutPrefix(lit, "-")
+	for _, tok := range []token.Token{token.INT, token.FLOAT, token.STRING} {
+		if value := constant.MakeFromLiteral(lit, tok, 0); value.Kind() != constant.Unknown {
+			if neg {
+				value = constant.UnaryOp(token.SUB, value, 0)
+			}
+			return value
+		}
+	}
+	return nil
+}
+
+// indexedBy returns true if p selects an element with an index referring to the variable v
+func (p accessPath) indexedBy(v types.Object) bool {
+	for _, e := range p.elems {
+		if e.index == nil {
+			continue
+		}
+		for _, found := range e.index.vars {
+			if found == v {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,561 @@
+package sa
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/cfg"
+)
+
+// lockset is the set of locks that may be held at a point in a function, keyed by their access paths (see
+// accessPath.key). Locksets are never modified once they are built, so every operation returns a new one if it changes
+// anything.
+type lockset map[string]accessPath
+
+// contains returns true if the lock acquired by lock, with the same lock method, is in l
+func (l lockset) contains(lock accessPath) bool {
+	_, found := l[lock.key]
+	return found
+}
+
+// with returns a copy of l, changed by change
+func (l lockset) with(change func(l lockset)) lockset {
+	ret := make(lockset, len(l)+1)
+	for key, p := range l {
+		ret[key] = p
+	}
+	change(ret)
+	return ret
+}
+
+// add returns a copy of l holding the lock acquired by lock
+func (l lockset) add(lock accessPath) lockset {
+	if l.contains(lock) {
+		return l
+	}
+	return l.with(func(l lockset) { l[lock.key] = lock })
+}
+
+// remove returns a copy of l without the lock acquired with lockMethod that is released by unlock
+func (l lockset) remove(unlock accessPath, lockMethod string) lockset {
+	held, found := l.find(unlock, lockMethod)
+	if !found {
+		return l
+	}
+	return l.with(func(l lockset) { delete(l, held.key) })
+}
+
+// find returns the path in l calling method on the same mutex as p, or false if there is none
+func (l lockset) find(p accessPath, method string) (accessPath, bool) {
+	found, ok := l[p.withMethod(method).key]
+	return found, ok
+}
+
+// union merges two locksets at a join point. A lock held on any incoming path may be held after the join.
+func (l lockset) union(l2 lockset) lockset {
+	for _, held := range l2 {
+		l = l.add(held)
+	}
+	return l
+}
+
+// intersect merges two sets of unlock calls deferred on every path at a join point, keeping the ones deferred on both
+func (l lockset) intersect(l2 lockset) lockset {
+	ret := l
+	for _, unlock := range l {
+		if !l2.contains(unlock) {
+			ret = ret.remove(unlock, unlock.method())
+		}
+	}
+	return ret
+}
+
+// leaks returns the locks in l that are not released by any of the deferred unlock calls
+func (l lockset) leaks(deferred lockset) lockset {
+	ret := l
+	for _, held := range l {
+		if _, released := deferred.find(held, unlockMethods[held.method()]); released {
+			ret = ret.remove(held, held.method())
+		}
+	}
+	return ret
+}
+
+// forget returns a copy of l without the locks selected with an index referring to the variable v. The index of such a
+// lock selects another element once the variable is assigned, like at every iteration of a loop.
+func (l lockset) forget(v types.Object) lockset {
+	ret := l
+	for _, held := range l {
+		if held.indexedBy(v) {
+			ret = ret.remove(held, held.method())
+		}
+	}
+	return ret
+}
+
+func (l lockset) equal(l2 lockset) bool {
+	if len(l) != len(l2) {
+		return false
+	}
+	for _, held := range l2 {
+		if !l.contains(held) {
+			return false
+		}
+	}
+	return true
+}
+
+// sorted returns the paths in l in the order they were found in, so reports do not depend on the order maps are
+// iterated in
+func (l lockset) sorted() []accessPath {
+	paths := make([]accessPath, 0, len(l))
+	for _, p := range l {
+		paths = append(paths, p)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if paths[i].pos != paths[j].pos {
+			return paths[i].pos < paths[j].pos
+		}
+		return paths[i].key < paths[j].key
+	})
+	return paths
+}
+
+// unlockMethods maps each lock method to the method releasing it
+var unlockMethods = map[string]string{
+	"RLock": "RUnlock",
+	"Lock":  "Unlock",
+}
+
+// releasedMethods maps each unlock method to the lock method it releases
+var releasedMethods = map[string]string{
+	"RUnlock": "RLock",
+	"Unlock":  "Lock",
+}
+
+// lockState is the state lockFlow tracks through a function
+type lockState struct {
+	held     lockset // locks that may be held
+	deferred lockset // unlock calls deferred until the function returns
+	// unlock calls deferred on every path, which are the only ones checked against released (see lockFlow.checkExit)
+	alwaysDeferred lockset
+	released       lockset // unlock calls that may have released their lock, which has not been acquired again since
+	// lock calls acquiring a lock that was already held. The next release of the lock leaves it held.
+	reacquired lockset
+	spawned    lockset // held locks acquired by a goroutine started since they were acquired (see lockFlow.spawns)
+}
+
+func (s lockState) union(s2 lockState) lockState {
+	return lockState{
+		held:           s.held.union(s2.held),
+		deferred:       s.deferred.union(s2.deferred),
+		alwaysDeferred: s.alwaysDeferred.intersect(s2.alwaysDeferred),
+		released:       s.released.union(s2.released),
+		reacquired:     s.reacquired.union(s2.reacquired),
+		spawned:        s.spawned.union(s2.spawned),
+	}
+}
+
+// acquire returns s after a call acquiring lock. A lock acquired again while it is held is recorded in reacquired, so
+// the next release leaves it held.
+func (s lockState) acquire(lock accessPath) lockState {
+	if s.held.contains(lock) {
+		s.reacquired = s.reacquired.add(lock)
+	}
+	s.held = s.held.add(lock)
+	s.released = s.released.remove(lock, unlockMethods[lock.method()])
+	return s
+}
+
+func (s lockState) forget(v types.Object) lockState {
+	return lockState{
+		held:           s.held.forget(v),
+		deferred:       s.deferred.forget(v),
+		alwaysDeferred: s.alwaysDeferred.forget(v),
+		released:       s.released.forget(v),
+		reacquired:     s.reacquired.forget(v),
+		spawned:        s.spawned.forget(v),
+	}
+}
+
+func (s lockState) equal(s2 lockState) bool {
+	return s.held.equal(s2.held) && s.deferred.equal(s2.deferred) && s.alwaysDeferred.equal(s2.alwaysDeferred) &&
+		s.released.equal(s2.released) &&
+		s.reacquired.equal(s2.reacquired) && s.spawned.equal(s2.spawned)
+}
+
+// lockFlow is a forward dataflow analysis over the control-flow graph of a single function.
+// It computes the locks that may be held at the start of every block.
+type lockFlow struct {
+	pass      *analysis.Pass
+	inspect   *inspector.Inspector
+	summaries *lockSummaries
+	body      *ast.BlockStmt
+	in        map[*cfg.Block]lockState // missing blocks have not been reached (yet)
+
+	// onCall, if set, is called by report for every call with the state of the function when it is made. lock is the
+	// zero accessPath if the call is not a lock call and its function is not selected by a chain of selectors.
+	onCall func(state lockState, lock accessPath, call *callInfo)
+	// onNode, if set, is called by report for every node the function runs, before the calls it makes are applied
+	onNode func(state lockState, node ast.Node)
+	// onExit, if set, is called by report for every return, and for the end of the body if it can be reached
+	onExit func(state lockState, exit token.Pos)
+	// onWait, if set, is called by report for every channel receive and sync.WaitGroup.Wait call
+	onWait func(state lockState, wait ast.Expr)
+	// onLit, if set, is called by report for every function literal called in place, like func() {...}(), which runs
+	// under the locks held by the function
+	onLit func(held lockset, lit *ast.FuncLit, call *ast.CallExpr)
+
+	// spawns holds the first goroutine started while holding each lock in lockState.spawned, for reports
+	spawns map[string]spawn
+}
+
+// solve iterates over the blocks of g until the lockset at the start of every block stops changing
+func (f *lockFlow) solve(g *cfg.CFG) {
+	if len(g.Blocks) == 0 {
+		return
+	}
+	f.in[g.Blocks[0]] = lockState{}
+	work := []*cfg.Block{g.Blocks[0]}
+	queued := map[*cfg.Block]bool{g.Blocks[0]: true}
+	for len(work) > 0 {
+		b := work[0]
+		work = work[1:]
+		queued[b] = false
+		out := f.transfer(b, f.in[b], false)
+		for i, succ := range b.Succs {
+			old, reached := f.in[succ]
+			merged := f.branch(b, i, out)
+			if reached {
+				merged = old.union(merged)
+			}
+			if reached && merged.equal(old) {
+				continue
+			}
+			f.in[succ] = merged
+			if !queued[succ] {
+				queued[succ] = true
+				work = append(work, succ)
+			}
+		}
+	}
+}
+
+// report walks every reachable block once more, now that the locksets are stable, and passes every call to onCall
+// and every exit of the function to onExit
+func (f *lockFlow) report(g *cfg.CFG) {
+	// a block ending in a call that never returns is followed by an unreachable block for that call, and a select
+	// without a default case blocks forever once none of its cases is ready
+	noReturn := make(map[ast.Stmt]bool)
+	for _, b := range g.Blocks {
+		if b.Kind == cfg.KindUnreachable {
+			noReturn[b.Stmt] = true
+		}
+	}
+	for _, b := range g.Blocks {
+		state, reached := f.in[b]
+		if !reached {
+			continue
+		}
+		out := f.transfer(b, state, true)
+		if f.onExit == nil || len(b.Succs) > 0 || b.Kind == cfg.KindSelectAfterCase {
+			continue
+		}
+		if ret := b.Return(); ret != nil {
+			f.onExit(out, ret.Pos())
+		} else if len(b.Nodes) == 0 || !noReturn[asStmt(b.Nodes[len(b.Nodes)-1])] {
+			f.onExit(out, f.body.Rbrace)
+		}
+	}
+}
+
+// asStmt returns n if it is a statement, or nil
+func asStmt(n ast.Node) ast.Stmt {
+	stmt, _ := n.(ast.Stmt)
+	return stmt
+}
+
+// transfer applies the lock and unlock calls in b to state and returns the state at the end of b.
+// Function literals are analyzed on their own, and deferred calls only run once the function returns,
+// so neither is followed here. The unlock calls of deferred calls are recorded instead. Locks selected with an index
+// are forgotten once a variable the index refers to is assigned (see lockset.forget).
+func (f *lockFlow) transfer(b *cfg.Block, state lockState, report bool) lockState {
+	held, deferred, released, reacquired, spawned := state.held, state.deferred, state.released, state.reacquired, state.spawned
+	alwaysDeferred := state.alwaysDeferred
+	current := func() lockState {
+		return lockState{held: held, deferred: deferred, alwaysDeferred: alwaysDeferred, released: released,
+			reacquired: reacquired, spawned: spawned}
+	}
+	acquire := func(lock accessPath) {
+		state := current().acquire(lock)
+		held, released, reacquired = state.held, state.released, state.reacquired
+	}
+	release := func(unlock accessPath) {
+		lockMethod := releasedMethods[unlock.method()]
+		if _, found := reacquired.find(unlock, lockMethod); found {
+			reacquired = reacquired.remove(unlock, lockMethod)
+			return
+		}
+		if _, found := held.find(unlock, lockMethod); !found { // a mismatched release still releases the lock
+			held = held.remove(unlock, otherLockMethod(lockMethod))
+		}
+		held = held.remove(unlock, lockMethod)
+		released = released.add(unlock)
+		spawned = spawned.remove(unlock, lockMethod).remove(unlock, otherLockMethod(lockMethod))
+	}
+	visitCall := func(stmt *ast.CallExpr) {
+		if lit := invokedLit(stmt); lit != nil && report && f.onLit != nil {
+			f.onLit(held, lit, stmt)
+		}
+		call := getCallInfo(f.pass.TypesInfo, stmt)
+		if call == nil {
+			return
+		}
+		lock, ok := f.summaries.callPath(stmt)
+		if !ok && (isLockMethod(call.id) || tryLockMethods[call.id] != "") { // other calls, like (*T).M(r), are followed through their callInfo
+			return
+		}
+		if report && f.onCall != nil {
+			f.onCall(current(), lock, call)
+		}
+		switch call.id {
+		case "RLock", "Lock":
+			acquire(lock)
+		case "RUnlock", "Unlock":
+			release(lock)
+		default:
+			releases, acquires := f.summaries.callEvents(stmt) // s.lockRead(), unlock()
+			for _, unlock := range releases {
+				release(unlock)
+			}
+			for _, lock := range acquires {
+				acquire(lock)
+			}
+		}
+	}
+	assign := func(v types.Object) {
+		if v == nil {
+			return
+		}
+		state := current().forget(v)
+		held, deferred, released, reacquired, spawned = state.held, state.deferred, state.released, state.reacquired, state.spawned
+		alwaysDeferred = state.alwaysDeferred
+	}
+	var visit func(node ast.Node) bool
+	visit = func(node ast.Node) bool {
+		if report && f.onNode != nil && node != nil {
+			if _, isLit := node.(*ast.FuncLit); !isLit {
+				f.onNode(current(), node)
+			}
+		}
+		switch stmt := node.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.DeferStmt:
+			if inner, ok := ast.Unparen(stmt.Call.Fun).(*ast.CallExpr); ok { // defer s.rlock()() calls s.rlock() now
+				visitCall(inner)
+			}
+			for _, unlock := range f.deferredUnlocks(stmt) {
+				deferred = deferred.add(unlock)
+				alwaysDeferred = alwaysDeferred.add(unlock)
+			}
+			return false
+		case *ast.GoStmt: // the goroutine starts out without any locks held
+			for _, e := range goEvaluated(stmt) {
+				ast.Inspect(e, visit)
+			}
+			for _, lock := range f.spawnConflicts(held, stmt) {
+				spawned = spawned.add(lock)
+			}
+			return false
+		case *ast.CallExpr:
+			visitCall(stmt)
+			if _, isWait := f.isWait(stmt); isWait && report && f.onWait != nil {
+				f.onWait(current(), stmt)
+			}
+		case *ast.UnaryExpr:
+			if _, isWait := f.isWait(stmt); isWait && report && f.onWait != nil {
+				f.onWait(current(), stmt)
+			}
+		case *ast.AssignStmt: // the variables are assigned once the calls on both sides are made
+			for _, e := range append(stmt.Rhs[:len(stmt.Rhs):len(stmt.Rhs)], stmt.Lhs...) {
+				ast.Inspect(e, visit)
+			}
+			for _, lhs := range stmt.Lhs {
+				assign(assignedVar(f.pass.TypesInfo, lhs))
+			}
+			return false
+		case *ast.IncDecStmt:
+			assign(assignedVar(f.pass.TypesInfo, stmt.X))
+		case *ast.ValueSpec:
+			for _, value := range stmt.Values {
+				ast.Inspect(value, visit)
+			}
+			for _, name := range stmt.Names {
+				assign(f.pass.TypesInfo.Defs[name])
+			}
+			return false
+		}
+		return true
+	}
+	for _, v := range loopVars(f.pass.TypesInfo, b) {
+		assign(v)
+	}
+	for _, n := range b.Nodes {
+		ast.Inspect(n, visit)
+	}
+	return current()
+}
+
+// loopVars returns the variables of the loop b belongs to that select another element at the start of b: the key and
+// value of a range loop, assigned at every iteration, and the variables declared by a loop once it is done
+func loopVars(info *types.Info, b *cfg.Block) (vars []types.Object) {
+	switch loop := b.Stmt.(type) {
+	case *ast.RangeStmt:
+		if b.Kind == cfg.KindRangeBody || (b.Kind == cfg.KindRangeDone && loop.Tok == token.DEFINE) {
+			for _, e := range []ast.Expr{loop.Key, loop.Value} {
+				if e != nil {
+					vars = append(vars, assignedVar(info, e))
+				}
+			}
+		}
+	case *ast.ForStmt:
+		if init, ok := loop.Init.(*ast.AssignStmt); ok && b.Kind == cfg.KindForDone && init.Tok == token.DEFINE {
+			for _, lhs := range init.Lhs {
+				vars = append(vars, assignedVar(info, lhs))
+			}
+		}
+	}
+	return vars
+}
+
+// otherLockMethod returns RLock for Lock and Lock for RLock
+func otherLockMethod(lockMethod string) string {
+	if lockMethod == "RLock" {
+		return "Lock"
+	}
+	return "RLock"
+}
+
+// deferredUnlocks returns the access paths of the unlock calls run by a deferred call, either the deferred call itself
+// or the calls of a deferred function literal. Calls to helpers releasing locks count as unlock calls (see lockHelper).
+func (f *lockFlow) deferredUnlocks(stmt *ast.DeferStmt) (unlocks []accessPath) {
+	if _, ok := ast.Unparen(stmt.Call.Fun).(*ast.CallExpr); ok { // defer s.rlock()()
+		unlocks, _ = f.summaries.callEvents(stmt.Call)
+		return unlocks
+	}
+	var root ast.Node = stmt.Call
+	if lit, ok := stmt.Call.Fun.(*ast.FuncLit); ok {
+		root = lit.Body
+	}
+	ast.Inspect(root, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.CallExpr:
+			if call := getCallInfo(f.pass.TypesInfo, n); call != nil && (call.id == "RUnlock" || call.id == "Unlock") {
+				if unlock, ok := f.summaries.callPath(n); ok {
+					unlocks = append(unlocks, unlock)
+				}
+			} else {
+				releases, _ := f.summaries.callEvents(n)
+				unlocks = append(unlocks, releases...)
+			}
+		}
+		return true
+	})
+	return unlocks
+}
+
+// checkRelease reports an unlock call if the lock it releases may already have been released in the function, or if
+// it was acquired with the other lock method. Locks released without being acquired in the function at all are
+// assumed to be held by the caller.
+func (f *lockFlow) checkRelease(state lockState, unlock accessPath, call *callInfo) {
+	lockMethod := releasedMethods[unlock.method()]
+	if _, found := state.reacquired.find(unlock, lockMethod); found {
+		return
+	}
+	if prev, found := state.released.find(unlock, unlock.method()); found {
+		reportFrames(f.pass, call.call.Pos(), errUnlockNotHeld.Error(), []callFrame{
+			newFrame(prev.method(), prev.pos),
+			newFrame(unlock.method(), call.call.Pos()),
+		})
+		return
+	}
+	if _, found := state.held.find(unlock, lockMethod); found {
+		return
+	}
+	if acquired, found := state.held.find(unlock, otherLockMethod(lockMethod)); found {
+		reportFrames(f.pass, call.call.Pos(), mismatchedUnlockErrors[unlock.method()].Error(), []callFrame{
+			newFrame(acquired.method(), acquired.pos),
+			newFrame(unlock.method(), call.call.Pos()),
+		})
+	}
+}
+
+// checkExit reports exit if a lock acquired in the function may still be held there without a deferred call releasing
+// it, or if a deferred call made on every path releases a lock that may already have been released. A call deferred
+// on some paths only may release a lock released on the others, which it was acquired again for.
+func (f *lockFlow) checkExit(state lockState, exit token.Pos) {
+	for _, lock := range state.held.leaks(state.deferred).sorted() {
+		if f.returnsHolding(lock) { // the lock is held for the caller, like s.mu.RLock() in s.lockRead()
+			continue
+		}
+		reportFrames(f.pass, exit, errLockLeak.Error(), []callFrame{
+			newFrame(lock.method(), lock.pos),
+			newFrame("return", exit),
+		})
+	}
+	for _, unlock := range state.alwaysDeferred.sorted() {
+		if prev, found := state.released.find(unlock, unlock.method()); found {
+			reportFrames(f.pass, exit, errDeferredUnlockNotHeld.Error(), []callFrame{
+				newFrame("defer "+unlock.method(), unlock.pos),
+				newFrame(prev.method(), prev.pos),
+				newFrame("return", exit),
+			})
+		}
+	}
+}
+
+// checkCall reports call if it acquires, directly or through the functions it calls, a lock in held. The error
+// reported depends on how the held lock and the nested lock were acquired (see nestedLockErrors).
+func (f *lockFlow) checkCall(held lockset, lock accessPath, call *callInfo) {
+	if isLockMethod(call.id) || tryLockMethods[call.id] != "" {
+		f.checkLock(held, lock, call)
+		return
+	}
+	f.checkLocks(held, call.call, append(f.summaries.callLocks(call), f.summaries.invokedLitLocks(call)...))
+}
+
+// checkLocks reports call if it acquires one of locks while a lock in held is held, preferring nested RLocks over
+// nested Locks
+func (f *lockFlow) checkLocks(held lockset, call *ast.CallExpr, locks []summaryLock) {
+	for _, lockMethod := range []string{"RLock", "Lock"} {
+		for _, heldPath := range held.sorted() {
+			for _, l := range locks {
+				if l.method() == lockMethod && l.aliases(heldPath) {
+					outer := newFrame(heldPath.method(), heldPath.pos)
+					reportFrames(f.pass, call.Pos(), nestedLockErrors[heldPath.method()][lockMethod].Error(), append([]callFrame{outer}, l.stack...))
+					return
+				}
+			}
+		}
+	}
+}
+
+// checkLock reports the lock call on path if it acquires a lock in held
+func (f *lockFlow) checkLock(held lockset, path accessPath, call *callInfo) {
+	for _, heldPath := range held.sorted() {
+		if err := nestedLockErrors[heldPath.method()][path.method()]; err != nil && heldPath.aliases(path) {
+			reportFrames(f.pass, call.call.Pos(), err.Error(), []callFrame{
+				newFrame(heldPath.method(), heldPath.pos),
+				newFrame(path.method(), call.call.Pos()),
+			})
+			return
+		}
+	}
+}
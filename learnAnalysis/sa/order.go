@@ -0,0 +1,400 @@
+package sa
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/ctrlflow"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/cfg"
+)
+
+// OrderAnalyzer checks for locks acquired in opposite orders by different functions, which deadlock once both
+// orders run at the same time (ABBA).
+var OrderAnalyzer = &analysis.Analyzer{
+	Name:      "lockorder",
+	Doc:       "Checks for lock order inversions between functions",
+	Requires:  []*analysis.Analyzer{inspect.Analyzer, ctrlflow.Analyzer, summariesAnalyzer},
+	Run:       runOrder,
+	FactTypes: []analysis.Fact{new(lockClassFact), new(lockOrderFact)},
+}
+
+var errLockOrder = errors.New("found lock order inversion")
+
+// lockClassFact is exported for every exported function or method that may acquire a lock when it is called.
+// Unlike lockFact, locks are identified by their class (see lockClass), so the locks acquired by a callee
+// never have to be rooted at the selectors of the call.
+type lockClassFact struct {
+	Locks []classAcquisition
+}
+
+// classAcquisition describes a single lock class acquired by a function, either directly or through the functions it calls.
+type classAcquisition struct {
+	Class string
+	Stack []callFrame // calls leading to the acquisition, ending with the lock call itself
+}
+
+// lockOrderFact is exported for every package that acquires a lock while holding another, directly or through
+// the packages it imports. It holds every edge of the lock order graph known to the package.
+type lockOrderFact struct {
+	Edges []lockOrderEdge
+}
+
+// lockOrderEdge records that the lock class To was acquired while the lock class From was held.
+type lockOrderEdge struct {
+	From, To string
+	Stack    []callFrame // the acquisition of From, followed by the calls leading to the acquisition of To
+}
+
+func (*lockClassFact) AFact() {}
+
+func (f *lockClassFact) String() string {
+	classes := make([]string, len(f.Locks))
+	for i, l := range f.Locks {
+		classes[i] = l.Class
+	}
+	return "acquires " + strings.Join(classes, ", ")
+}
+
+func (*lockOrderFact) AFact() {}
+
+func (f *lockOrderFact) String() string {
+	edges := make([]string, len(f.Edges))
+	for i, e := range f.Edges {
+		edges[i] = e.From + " -> " + e.To
+	}
+	return "orders " + strings.Join(edges, ", ")
+}
+
+func (e lockOrderEdge) String() string {
+	return fmt.Sprintf("%v acquired while holding %v", e.To, e.From)
+}
+
+// localEdge is an edge of the lock order graph found in the package being analyzed, along with the call it was found at
+type localEdge struct {
+	lockOrderEdge
+	pos token.Pos
+}
+
+func runOrder(pass *analysis.Pass) (interface{}, error) {
+	inspect, ok := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	if !ok {
+		return nil, errors.New("analyzer is not type *inspector.Inspector")
+	}
+	cfgs, ok := pass.ResultOf[ctrlflow.Analyzer].(*ctrlflow.CFGs)
+	if !ok {
+		return nil, errors.New("analyzer is not type *ctrlflow.CFGs")
+	}
+	summaries, ok := pass.ResultOf[summariesAnalyzer].(*lockSummaries)
+	if !ok {
+		return nil, errors.New("analyzer is not type *lockSummaries")
+	}
+	classes := newClassSummaries(pass, summaries)
+
+	// every function body, including function literals, starts out without any locks held
+	var edges []localEdge
+	nodeFilter := []ast.Node{
+		(*ast.FuncDecl)(nil),
+		(*ast.FuncLit)(nil),
+	}
+	inspect.Preorder(nodeFilter, func(node ast.Node) {
+		var g *cfg.CFG
+		var body *ast.BlockStmt
+		switch funcNode := node.(type) {
+		case *ast.FuncDecl:
+			if funcNode.Name.IsExported() {
+				if locks := classes.done[funcNode]; len(locks) > 0 {
+					fact := &lockClassFact{Locks: make([]classAcquisition, len(locks))}
+					for i, l := range locks {
+						fact.Locks[i] = classAcquisition{Class: l.Class, Stack: exportFrames(pass.Fset, l.Stack)}
+					}
+					pass.ExportObjectFact(pass.TypesInfo.ObjectOf(funcNode.Name), fact)
+				}
+			}
+			g, body = cfgs.FuncDecl(funcNode), funcNode.Body
+		case *ast.FuncLit:
+			g, body = cfgs.FuncLit(funcNode), funcNode.Body
+		}
+		if g == nil {
+			return
+		}
+		flow := &lockFlow{
+			pass:      pass,
+			inspect:   inspect,
+			summaries: classes.locks,
+			body:      body,
+			in:        make(map[*cfg.Block]lockState),
+			spawns:    make(map[string]spawn),
+			onCall: func(state lockState, lock accessPath, call *callInfo) {
+				edges = append(edges, classes.orderEdges(state.held, lock, call)...)
+			},
+		}
+		flow.solve(g)
+		flow.report(g)
+	})
+
+	// the graph holds the edges found here and every edge known to the imported packages, which already
+	// include the edges of their own imports
+	graph := make(map[string][]lockOrderEdge)
+	var known []lockOrderEdge
+	addEdge := func(e lockOrderEdge) {
+		for _, found := range graph[e.From] {
+			if found.To == e.To {
+				return
+			}
+		}
+		graph[e.From] = append(graph[e.From], e)
+		known = append(known, e)
+	}
+	for _, e := range edges {
+		addEdge(e.lockOrderEdge)
+	}
+	for _, imp := range pass.Pkg.Imports() {
+		var fact lockOrderFact
+		if pass.ImportPackageFact(imp, &fact) {
+			for _, e := range fact.Edges {
+				addEdge(e)
+			}
+		}
+	}
+	if len(known) > 0 {
+		fact := &lockOrderFact{Edges: make([]lockOrderEdge, len(known))}
+		for i, e := range known {
+			fact.Edges[i] = lockOrderEdge{From: e.From, To: e.To, Stack: exportFrames(pass.Fset, e.Stack)}
+		}
+		pass.ExportPackageFact(fact)
+	}
+
+	// every inverted pair of lock classes is reported once, at the first edge between them found here
+	sort.SliceStable(edges, func(i, j int) bool { return edges[i].pos < edges[j].pos })
+	reported := make(map[[2]string]bool)
+	for _, e := range edges {
+		pair := [2]string{e.From, e.To}
+		if pair[1] < pair[0] {
+			pair = [2]string{e.To, e.From}
+		}
+		if reported[pair] {
+			continue
+		}
+		cycle := orderPath(graph, e.To, e.From)
+		if cycle == nil {
+			continue
+		}
+		reported[pair] = true
+		// the frames of the conflicting acquisitions follow the frames of this one, and are told apart by their names
+		frames := append([]callFrame(nil), e.Stack...)
+		for _, c := range cycle {
+			for _, frame := range c.Stack {
+				frame.Name = fmt.Sprintf("%v (%v)", frame.Name, c)
+				frames = append(frames, frame)
+			}
+		}
+		reportFrames(pass, e.pos, fmt.Sprintf("%v: %v", errLockOrder, e.lockOrderEdge), frames)
+	}
+	return nil, nil
+}
+
+// orderPath returns the edges of the shortest path from the lock class from to the lock class to, or nil if there is none
+func orderPath(graph map[string][]lockOrderEdge, from, to string) []lockOrderEdge {
+	prev := map[string]lockOrderEdge{}
+	visited := map[string]bool{from: true}
+	queue := []string{from}
+	for len(queue) > 0 {
+		class := queue[0]
+		queue = queue[1:]
+		for _, e := range graph[class] {
+			if visited[e.To] {
+				continue
+			}
+			visited[e.To] = true
+			prev[e.To] = e
+			if e.To != to {
+				queue = append(queue, e.To)
+				continue
+			}
+			var path []lockOrderEdge
+			for c := to; c != from; c = prev[c].From {
+				path = append([]lockOrderEdge{prev[c]}, path...)
+			}
+			return path
+		}
+	}
+	return nil
+}
+
+// lockClass identifies the lock acquired or released by the call on path independently of the function the
+// call is made in: a mutex field by the type it is declared in, a package-level mutex by its name, and a mutex embedded
+// in a type by that type. Locks held in local variables have no class and an empty string is returned.
+func lockClass(path accessPath) string {
+	if path.isZero() {
+		return ""
+	}
+	objs := path.objs()
+	if len(objs) < 2 {
+		return ""
+	}
+	holder, ok := objs[len(objs)-2].(*types.Var) // the value the lock method is called on
+	if !ok {
+		return ""
+	}
+	if name := typeName(holder.Type()); name != "" && !strings.HasPrefix(name, "sync.") {
+		return name
+	}
+	switch {
+	case holder.IsField():
+		if len(objs) < 3 {
+			return ""
+		}
+		if owner, ok := objs[len(objs)-3].(*types.Var); ok {
+			if name := typeName(owner.Type()); name != "" {
+				return name + "." + holder.Name()
+			}
+		}
+	case holder.Pkg() != nil && holder.Parent() == holder.Pkg().Scope():
+		return holder.Pkg().Path() + "." + holder.Name()
+	}
+	return ""
+}
+
+// typeName returns the package path and name of the named type t points to or is, or an empty string if it has no name
+func typeName(t types.Type) string {
+	named, ok := types.Unalias(deref(t)).(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return ""
+	}
+	return named.Obj().Pkg().Path() + "." + named.Obj().Name()
+}
+
+// classSummaries holds the lock classes acquired by the functions declared in a package
+type classSummaries struct {
+	pass  *analysis.Pass
+	locks *lockSummaries
+	done  map[*ast.FuncDecl][]classAcquisition
+	lits  map[*ast.BlockStmt]bool // function literal bodies being summarized, to stop literals calling themselves
+}
+
+// newClassSummaries summarizes the lock classes acquired by every function declared in the package bottom-up, over the
+// same strongly connected components as the lock summaries: the functions of a component are summarized until their
+// summaries stop changing, so recursive calls see every class acquired by the functions they call.
+func newClassSummaries(pass *analysis.Pass, locks *lockSummaries) *classSummaries {
+	s := &classSummaries{
+		pass:  pass,
+		locks: locks,
+		done:  make(map[*ast.FuncDecl][]classAcquisition),
+		lits:  make(map[*ast.BlockStmt]bool),
+	}
+	for _, scc := range locks.order {
+		for changed := true; changed; {
+			changed = false
+			for _, funcDec := range scc {
+				classes := s.summarize(funcDec.Body)
+				if !sameKeys(classes, s.done[funcDec], func(l classAcquisition) string { return l.Class }) {
+					changed = true
+					s.done[funcDec] = classes
+				}
+			}
+		}
+	}
+	return s
+}
+
+// summarize returns the lock classes acquired by body in the goroutine running it, following the same calls and
+// function literals as the lock summaries
+func (s *classSummaries) summarize(body *ast.BlockStmt) (locks []classAcquisition) {
+	s.locks.calls(body, func(c *callInfo) {
+		lock, _ := s.locks.callPath(c.call)
+		for _, l := range s.callLocks(c, lock) {
+			locks = addClass(locks, l)
+		}
+	})
+	return locks
+}
+
+// callLocks returns the lock classes acquired by c, either by the call itself or by the functions it calls
+func (s *classSummaries) callLocks(c *callInfo, lock accessPath) (locks []classAcquisition) {
+	switch c.id {
+	case "RLock", "Lock":
+		if class := lockClass(lock); class != "" {
+			locks = append(locks, classAcquisition{Class: class, Stack: []callFrame{newFrame(c.id, c.call.Pos())}})
+		}
+		return locks
+	case "RUnlock", "Unlock":
+		return nil
+	}
+	if block := s.locks.funcLitBlock(c); block != nil {
+		if s.lits[block] {
+			return nil
+		}
+		s.lits[block] = true
+		defer delete(s.lits, block)
+		frame := newFrame(c.name(), c.call.Pos())
+		for _, l := range s.summarize(block) {
+			l.Stack = append([]callFrame{frame}, l.Stack...)
+			locks = addClass(locks, l)
+		}
+		return locks
+	}
+	for _, callee := range s.locks.callTargets(c) {
+		frame := newFrame(callee.name(), c.call.Pos())
+		for _, l := range s.calleeLocks(callee) {
+			l.Stack = append([]callFrame{frame}, l.Stack...)
+			locks = addClass(locks, l)
+		}
+	}
+	return locks
+}
+
+// calleeLocks returns the lock classes acquired by the function called by c, looking in the current package before imported facts
+func (s *classSummaries) calleeLocks(c *callInfo) []classAcquisition {
+	if c.obj.Pkg() == s.pass.Pkg {
+		if funcDec := s.locks.decls.of(c.obj); funcDec != nil {
+			return s.done[funcDec]
+		}
+		return nil
+	}
+	var fact lockClassFact
+	if !s.pass.ImportObjectFact(c.obj, &fact) {
+		return nil
+	}
+	return fact.Locks
+}
+
+// orderEdges returns an edge from every lock class in held to every other lock class acquired by call
+func (s *classSummaries) orderEdges(held lockset, lock accessPath, call *callInfo) (edges []localEdge) {
+	if len(held) == 0 {
+		return nil
+	}
+	acquired := s.callLocks(call, lock)
+	for _, heldPath := range held.sorted() {
+		from := lockClass(heldPath)
+		if from == "" {
+			continue
+		}
+		heldFrame := newFrame(heldPath.method(), heldPath.pos)
+		for _, l := range acquired {
+			if l.Class == from {
+				continue
+			}
+			edge := lockOrderEdge{From: from, To: l.Class, Stack: append([]callFrame{heldFrame}, l.Stack...)}
+			edges = append(edges, localEdge{lockOrderEdge: edge, pos: call.call.Pos()})
+		}
+	}
+	return edges
+}
+
+// addClass appends l to locks unless the same lock class was already found
+func addClass(locks []classAcquisition, l classAcquisition) []classAcquisition {
+	for _, found := range locks {
+		if found.Class == l.Class {
+			return locks
+		}
+	}
+	return append(locks, l)
+}
@@ -0,0 +1,251 @@
+package sa
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+)
+
+// accessPath identifies the lock a lock call acquires or releases: the variable the path is rooted at, followed by
+// the fields and elements selected from it and the lock method. Embedded fields selected implicitly are part of the
+// path (see embeddedFields), so every way of writing a selection of the same lock has the same path. The locks of
+// lock summaries are access paths as well, rooted at the parameters of the function they belong to until they are
+// substituted with the arguments of a call (see substitute).
+//
+// Paths are never modified once they are built, so they can be shared between locksets, and two paths selecting the
+// same lock with the same method have the same key.
+type accessPath struct {
+	root  types.Object // the variable the path is rooted at
+	elems []pathElem
+	key   string
+	pos   token.Pos // the call the path was found at, for reports. It is not part of the key.
+}
+
+// pathElem is a single selection of an accessPath
+type pathElem struct {
+	name  string       // the name of the selected field or method, or the index expression in brackets
+	obj   types.Object // the selected field or method, or nil for an index
+	field int          // the index of the selected field in its struct, or -1 for a method or an index
+	index *pathIndex   // the index of an index element (see newIndexElem), or nil for a selection
+}
+
+// key identifies e among the selections made from the same type: fields by their index, and methods and index
+// elements by their names
+func (e pathElem) key() string {
+	switch {
+	case e.field >= 0:
+		return "." + strconv.Itoa(e.field)
+	case e.index != nil:
+		return "[" + e.index.key + "]"
+	}
+	return "." + e.name
+}
+
+// newAccessPath returns the path of the variable root, found at pos
+func newAccessPath(root types.Object, pos token.Pos) accessPath {
+	return accessPath{root: root, key: rootKey(root), pos: pos}
+}
+
+// rootKey identifies the variable root: package-level variables by their package path and name, so they have the
+// same key in every package, and other variables by their declaration
+func rootKey(root types.Object) string {
+	if isGlobal(root) {
+		return root.Pkg().Path() + "." + root.Name()
+	}
+	return fmt.Sprintf("%v@%d", root.Name(), root.Pos())
+}
+
+// isGlobal returns true if obj is a package-level variable
+func isGlobal(obj types.Object) bool {
+	v, ok := obj.(*types.Var)
+	return ok && !v.IsField() && v.Pkg() != nil && v.Parent() == v.Pkg().Scope()
+}
+
+// isZero returns true if p is the zero accessPath, which selects no lock
+func (p accessPath) isZero() bool {
+	return p.root == nil
+}
+
+// extend returns a copy of p followed by elems. Lock methods called on the RLocker of a mutex become the read lock
+// methods of the mutex itself: mu.RLocker().Lock() read locks mu.
+func (p accessPath) extend(elems ...pathElem) accessPath {
+	p.elems = p.elems[:len(p.elems):len(p.elems)]
+	for _, e := range elems {
+		if n := len(p.elems); n > 0 && p.elems[n-1].name == "RLocker" {
+			if method, ok := readMethods[e.name]; ok {
+				e = readElem(p.elems[n-1], method)
+				p = p.prefix(n - 1)
+			}
+		}
+		p.elems = append(p.elems, e)
+		p.key += e.key()
+	}
+	return p
+}
+
+// readElem returns the element selecting the read lock method of the mutex the RLocker method rlocker is selected from
+func readElem(rlocker pathElem, method string) pathElem {
+	e := pathElem{name: method, field: -1}
+	if f, ok := rlocker.obj.(*types.Func); ok && f.Signature().Recv() != nil {
+		e.obj, _, _ = types.LookupFieldOrMethod(f.Signature().Recv().Type(), true, nil, method)
+	}
+	return e
+}
+
+// at returns p found at pos
+func (p accessPath) at(pos token.Pos) accessPath {
+	p.pos = pos
+	return p
+}
+
+// prefix returns the path made of the root of p and its first n selections
+func (p accessPath) prefix(n int) accessPath {
+	return newAccessPath(p.root, p.pos).extend(p.elems[:n]...)
+}
+
+// mutex returns the path of the mutex p calls its lock method on
+func (p accessPath) mutex() accessPath {
+	return p.prefix(len(p.elems) - 1)
+}
+
+// method returns the lock method p ends with
+func (p accessPath) method() string {
+	return p.elems[len(p.elems)-1].name
+}
+
+// withMethod returns p with its lock method substituted by method
+func (p accessPath) withMethod(method string) accessPath {
+	if p.method() == method {
+		return p
+	}
+	return p.mutex().extend(pathElem{name: method, field: -1})
+}
+
+// equal returns true if p and p2 select the same lock with the same method
+func (p accessPath) equal(p2 accessPath) bool {
+	return p.key == p2.key
+}
+
+// sameMutex returns true if p and p2 call their lock methods on the same mutex, regardless of the methods
+func (p accessPath) sameMutex(p2 accessPath) bool {
+	return len(p.elems) == len(p2.elems) && len(p.elems) > 0 && p.mutexKey() == p2.mutexKey()
+}
+
+// mutexKey returns the key of the mutex p calls its lock method on
+func (p accessPath) mutexKey() string {
+	return strings.TrimSuffix(p.key, p.elems[len(p.elems)-1].key())
+}
+
+// hasPrefix returns true if p starts with the whole of prefix
+func (p accessPath) hasPrefix(prefix accessPath) bool {
+	if len(p.elems) < len(prefix.elems) || rootKey(p.root) != rootKey(prefix.root) {
+		return false
+	}
+	for i, e := range prefix.elems {
+		if p.elems[i].key() != e.key() {
+			return false
+		}
+	}
+	return true
+}
+
+// substitute returns p rooted at root instead of its own root, with every index element replaced by index(elem), like
+// the lock of a summary rooted at a parameter is rooted at the argument of a call
+func (p accessPath) substitute(root accessPath, index func(pathElem) pathElem) accessPath {
+	elems := make([]pathElem, len(p.elems))
+	for i, e := range p.elems {
+		if e.index != nil {
+			e = index(e)
+		}
+		elems[i] = e
+	}
+	return root.extend(elems...)
+}
+
+// typ returns the type of the value p selects, or nil if it is unknown
+func (p accessPath) typ() types.Type {
+	t := p.root.Type()
+	for _, e := range p.elems {
+		switch obj := e.obj.(type) {
+		case *types.Var:
+			t = obj.Type()
+		case *types.Func:
+			if results := obj.Signature().Results(); results.Len() == 1 {
+				t = results.At(0).Type()
+			} else {
+				return nil
+			}
+		default:
+			if e.index == nil {
+				return nil
+			}
+			if t = indexElem(t); t == nil {
+				return nil
+			}
+		}
+	}
+	return t
+}
+
+// selectIndex returns p followed by the index element e, or false if the value p selects cannot be indexed
+func (p accessPath) selectIndex(e pathElem) (accessPath, bool) {
+	if t := p.typ(); t == nil || indexElem(t) == nil {
+		return accessPath{}, false
+	}
+	return p.extend(e), true
+}
+
+// selectName returns p followed by the selection of the field or method name from the value p selects, along with the
+// embedded fields the selection selects implicitly, or false if there is no such selection
+func (p accessPath) selectName(name string) (accessPath, bool) {
+	t := p.typ()
+	if t == nil {
+		return accessPath{}, false
+	}
+	pkg := p.root.Pkg()
+	if named, ok := types.Unalias(deref(t)).(*types.Named); ok && named.Obj().Pkg() != nil {
+		pkg = named.Obj().Pkg()
+	}
+	obj, index, _ := types.LookupFieldOrMethod(t, true, pkg, name)
+	if obj == nil {
+		return accessPath{}, false
+	}
+	return p.extend(selectionElems(t, index, obj)...), true
+}
+
+// names returns the names of the selections of p
+func (p accessPath) names() []string {
+	names := make([]string, len(p.elems))
+	for i, e := range p.elems {
+		names[i] = e.name
+	}
+	return names
+}
+
+// objs returns the root of p followed by the objects of its selections, which are nil for indices
+func (p accessPath) objs() []types.Object {
+	objs := []types.Object{p.root}
+	for _, e := range p.elems {
+		objs = append(objs, e.obj)
+	}
+	return objs
+}
+
+func (p accessPath) String() string {
+	return strings.Join(append([]string{p.root.Name()}, p.names()...), ".")
+}
+
+// selectionElems returns the elements of a selection of obj from a value of type t with the given index (see
+// types.Selection.Index): the embedded fields it selects implicitly, followed by obj itself
+func selectionElems(t types.Type, index []int, obj types.Object) (elems []pathElem) {
+	for _, field := range embeddedFields(t, index) {
+		elems = append(elems, pathElem{name: field.Name(), obj: field, field: index[len(elems)]})
+	}
+	field := -1
+	if v, ok := obj.(*types.Var); ok && v.IsField() {
+		field = index[len(index)-1]
+	}
+	return append(elems, pathElem{name: obj.Name(), obj: obj, field: field})
+}
@@ -0,0 +1,95 @@
+package sa
+
+import (
+	"go/token"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// newFrame returns the callFrame of the call named name at pos
+func newFrame(name string, pos token.Pos) callFrame {
+	return callFrame{Name: name, pos: pos}
+}
+
+// exportFrames returns a copy of frames with the position of every frame found in the package being analyzed stored
+// in Pos, which is all that is left of it once the fact holding the frames is serialized
+func exportFrames(fset *token.FileSet, frames []callFrame) []callFrame {
+	ret := make([]callFrame, len(frames))
+	for i, frame := range frames {
+		if frame.pos.IsValid() {
+			frame.Pos = fset.Position(frame.pos).String()
+		}
+		ret[i] = frame
+	}
+	return ret
+}
+
+// reportFrames reports message at pos with a related entry for every frame, so each lock and call leading to the
+// problem can be linked to on its own
+func reportFrames(pass *analysis.Pass, pos token.Pos, message string, frames []callFrame) {
+	var files map[string]*token.File // indexed on the first frame read from a fact
+	related := make([]analysis.RelatedInformation, len(frames))
+	for i, frame := range frames {
+		if frame.pos.IsValid() || frame.Pos == "" {
+			related[i] = frame.related(frame.pos, pos)
+			continue
+		}
+		if files == nil {
+			files = make(map[string]*token.File)
+			pass.Fset.Iterate(func(f *token.File) bool {
+				files[f.Name()] = f
+				return true
+			})
+		}
+		related[i] = frame.related(frame.resolve(files), pos)
+	}
+	pass.Report(analysis.Diagnostic{Pos: pos, Message: message, Related: related})
+}
+
+// related returns the related entry of frame at pos. Frames read from facts may point into files that are not part of
+// the file set, in which case the entry points at fallback and the position is kept in its message.
+func (frame callFrame) related(pos, fallback token.Pos) analysis.RelatedInformation {
+	if pos.IsValid() {
+		return analysis.RelatedInformation{Pos: pos, Message: frame.Name}
+	}
+	if frame.Pos == "" {
+		return analysis.RelatedInformation{Pos: fallback, Message: frame.Name}
+	}
+	return analysis.RelatedInformation{Pos: fallback, Message: frame.Name + " at " + frame.Pos}
+}
+
+// resolve returns the position of a frame read from a fact in the files it may point into, or token.NoPos if its file
+// is not one of them or the position is not part of the file
+func (frame callFrame) resolve(files map[string]*token.File) token.Pos {
+	// positions are formatted as file:line:column, and the file name may contain colons itself
+	colPos := strings.LastIndexByte(frame.Pos, ':')
+	if colPos < 0 {
+		return token.NoPos
+	}
+	linePos := strings.LastIndexByte(frame.Pos[:colPos], ':')
+	if linePos < 0 {
+		return token.NoPos
+	}
+	line, err := strconv.Atoi(frame.Pos[linePos+1 : colPos])
+	if err != nil {
+		return token.NoPos
+	}
+	col, err := strconv.Atoi(frame.Pos[colPos+1:])
+	if err != nil {
+		return token.NoPos
+	}
+	f := files[frame.Pos[:linePos]]
+	if f == nil || line < 1 || line > f.LineCount() {
+		return token.NoPos
+	}
+	start, end := f.Offset(f.LineStart(line)), f.Size()+1 // the end of the file is part of its last line
+	if line < f.LineCount() {
+		end = f.Offset(f.LineStart(line + 1))
+	}
+	if col < 1 || start+col-1 >= end {
+		return token.NoPos
+	}
+	return f.Pos(start + col - 1)
+}
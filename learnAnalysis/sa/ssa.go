@@ -0,0 +1,1011 @@
+package sa
+
+import (
+	"errors"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"slices"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/buildssa"
+	"golang.org/x/tools/go/ssa"
+)
+
+// ssaLock identifies the lock an SSA value refers to by the value it is rooted at and the fields selected from it.
+// Unlike accessPath it does not depend on how the lock was written, so getShard(k).RLock(), (&s.mu).RLock() and
+// locals aliasing a field can all be identified. Embedded fields are part of path, so promoted and explicit
+// selections of the same mutex are the same lock.
+type ssaLock struct {
+	root    ssa.Value // nil if the lock was read from the lockFact of another package
+	rootKey string
+	path    []string
+	indices []ssa.Value // the values of the index elements of path in order, nil for constant and unknown indices
+}
+
+func (l ssaLock) key() string {
+	return strings.Join(append([]string{l.rootKey}, l.path...), ".")
+}
+
+// extend returns a copy of l with path appended to its own
+func (l ssaLock) extend(path ...string) ssaLock {
+	l.path = append(l.path[:len(l.path):len(l.path)], path...)
+	return l
+}
+
+// index returns a copy of l selecting the element at index v
+func (l ssaLock) index(v ssa.Value) ssaLock {
+	l = l.extend(ssaIndexKey(v))
+	if isConstValue(v) {
+		v = nil
+	}
+	l.indices = append(l.indices[:len(l.indices):len(l.indices)], v)
+	return l
+}
+
+// join returns a copy of l with the path and indices of l2 appended to its own
+func (l ssaLock) join(l2 ssaLock) ssaLock {
+	l = l.extend(l2.path...)
+	l.indices = append(l.indices[:len(l.indices):len(l.indices)], l2.indices...)
+	return l
+}
+
+// ssaIndexKey returns the path element selecting the element at index v, written like the index element of the same
+// index expression if v is a constant (see constantElem)
+func ssaIndexKey(v ssa.Value) string {
+	if isConstValue(v) {
+		return "[" + v.(*ssa.Const).Value.ExactString() + "]"
+	}
+	return "[" + lockOf(v).key() + "]"
+}
+
+// isConstValue returns true if v is a constant with a known value
+func isConstValue(v ssa.Value) bool {
+	c, ok := v.(*ssa.Const)
+	return ok && c.Value != nil
+}
+
+// indexedBy returns true if l selects an element with the index v
+func (l ssaLock) indexedBy(v ssa.Value) bool {
+	for _, index := range l.indices {
+		if index == v {
+			return true
+		}
+	}
+	return false
+}
+
+// aliases returns true if l and l2 may be the same lock, comparing their indices like summaryLock.aliases
+func (l ssaLock) aliases(l2 ssaLock) bool {
+	return l.rootKey == l2.rootKey && samePathElems(l.path, l2.path)
+}
+
+// visibleOutside returns true if l can be identified by the callers of the function it was found in
+func (l ssaLock) visibleOutside() bool {
+	switch l.root.(type) {
+	case *ssa.Parameter, *ssa.FreeVar, *ssa.Global, nil:
+		return true
+	}
+	return false
+}
+
+// lockOf returns the lock v refers to by walking back through field selections, loads and conversions
+func lockOf(v ssa.Value) ssaLock {
+	switch v := v.(type) {
+	case *ssa.FieldAddr:
+		return lockOf(v.X).field(deref(v.X.Type()), v.Field)
+	case *ssa.Field:
+		return lockOf(v.X).field(v.X.Type(), v.Field)
+	case *ssa.IndexAddr:
+		return lockOf(v.X).index(v.Index)
+	case *ssa.Index:
+		return lockOf(v.X).index(v.Index)
+	case *ssa.Lookup:
+		return lockOf(v.X).index(v.Index)
+	case *ssa.UnOp:
+		if v.Op == token.MUL { // loading a pointer to a lock still refers to the same lock
+			return lockOf(v.X)
+		}
+	case *ssa.ChangeType:
+		return lockOf(v.X)
+	case *ssa.MakeInterface:
+		return lockOf(v.X)
+	case *ssa.Alloc:
+		if stored := storedPointer(v); stored != nil { // a local pointer aliasing a lock
+			return lockOf(stored)
+		}
+	case *ssa.Global:
+		return ssaLock{root: v, rootKey: v.Pkg.Pkg.Path() + "." + v.Name()}
+	case *ssa.Call:
+		if callee := v.Call.StaticCallee(); callee != nil && isRLocker(callee.Object()) {
+			return lockOf(v.Call.Args[0]).extend("RLocker")
+		}
+		return ssaLock{root: v, rootKey: v.String()}
+	}
+	return ssaLock{root: v, rootKey: v.Name()}
+}
+
+// view returns the method and lock a call to method on l acquires or releases. A lock obtained from RLocker() is a
+// read-lock view of its mutex, so its Lock and Unlock methods call RLock and RUnlock on the mutex.
+func (l ssaLock) view(method string) (string, ssaLock) {
+	if last := len(l.path) - 1; last >= 0 && l.path[last] == "RLocker" {
+		if read, ok := readMethods[method]; ok {
+			l.path = l.path[:last]
+			return read, l
+		}
+	}
+	return method, l
+}
+
+// field returns the lock found at field index i of the struct type t selected from l. Embedded fields are part of the
+// path like any other field, matching the paths of the ast engine (see selectionElems).
+func (l ssaLock) field(t types.Type, i int) ssaLock {
+	s, ok := t.Underlying().(*types.Struct)
+	if !ok {
+		return l
+	}
+	return l.extend(s.Field(i).Name())
+}
+
+func deref(t types.Type) types.Type {
+	if p, ok := t.Underlying().(*types.Pointer); ok {
+		return p.Elem()
+	}
+	return t
+}
+
+// storedPointer returns the value stored in a local pointer variable if it is only ever assigned once
+func storedPointer(alloc *ssa.Alloc) (stored ssa.Value) {
+	if _, isPointer := deref(alloc.Type()).Underlying().(*types.Pointer); !isPointer {
+		return nil
+	}
+	for _, ref := range *alloc.Referrers() {
+		if store, ok := ref.(*ssa.Store); ok && store.Addr == alloc {
+			if stored != nil {
+				return nil
+			}
+			stored = store.Val
+		}
+	}
+	return stored
+}
+
+// ssaAcquisition is a lock acquired by an SSA function, either directly or through the functions it calls
+type ssaAcquisition struct {
+	lock   ssaLock
+	method string // "RLock" or "Lock"
+	stack  []callFrame
+}
+
+// ssaSummaries holds the locks acquired by the SSA functions of a package. Only locks rooted at parameters, free
+// variables or globals are kept, since those are the only ones a caller can identify.
+type ssaSummaries struct {
+	pass  *analysis.Pass
+	locks *lockSummaries // imports the lockFacts of the functions of other packages
+	prog  *ssa.Program
+	done  map[*ssa.Function][]ssaAcquisition
+}
+
+// newSSASummaries summarizes the functions of the package bottom-up, like newLockSummaries: the strongly connected
+// components of the SSA call graph are visited callees first, and the functions of a component are summarized until
+// their summaries stop changing, so recursive calls see the complete summary of the functions they call.
+func newSSASummaries(pass *analysis.Pass, locks *lockSummaries, ssaInput *buildssa.SSA) *ssaSummaries {
+	s := &ssaSummaries{
+		pass:  pass,
+		locks: locks,
+		prog:  ssaInput.Pkg.Prog,
+		done:  make(map[*ssa.Function][]ssaAcquisition),
+	}
+	for _, scc := range stronglyConnected(ssaInput.SrcFuncs, s.callees) {
+		for changed := true; changed; {
+			changed = false
+			for _, fn := range scc {
+				if locks := s.summarize(fn); !sameKeys(locks, s.done[fn], ssaAcquisition.key) {
+					changed = true
+					s.done[fn] = locks
+				}
+			}
+		}
+	}
+	return s
+}
+
+// summarize returns the locks acquired by fn that its callers can identify, including the ones acquired by the
+// functions it calls. Paths are bounded like the ones of the lock summaries, so recursive functions walking a linked
+// structure reach a fixpoint.
+func (s *ssaSummaries) summarize(fn *ssa.Function) (locks []ssaAcquisition) {
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			call, ok := instr.(*ssa.Call)
+			if !ok {
+				continue
+			}
+			for _, l := range s.callLocks(call) {
+				if l.lock.visibleOutside() && len(l.lock.path) <= maxPathLength {
+					locks = addSSALock(locks, l)
+				}
+			}
+		}
+	}
+	return locks
+}
+
+// callees returns the functions with a body fn may call, including the closures it passes to the inPlaceCallers
+func (s *ssaSummaries) callees(fn *ssa.Function) (callees []*ssa.Function) {
+	add := func(callee *ssa.Function) {
+		if callee != nil && callee.Blocks != nil && !slices.Contains(callees, callee) {
+			callees = append(callees, callee)
+		}
+	}
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			call, ok := instr.(*ssa.Call)
+			if !ok {
+				continue
+			}
+			common := call.Common()
+			if common.IsInvoke() {
+				for _, f := range s.locks.decls.impls.of(common.Value.Type(), common.Method) {
+					add(s.prog.FuncValue(f.Origin()))
+				}
+				continue
+			}
+			callee := common.StaticCallee()
+			if callee == nil {
+				continue
+			}
+			add(callee)
+			for _, closure := range invokedClosures(callee, common) {
+				add(closure.fn)
+			}
+		}
+	}
+	return callees
+}
+
+// callLocks returns the locks acquired by call, rooted at values of the calling function
+func (s *ssaSummaries) callLocks(call *ssa.Call) (locks []ssaAcquisition) {
+	common := call.Common()
+	if method, lock, ok := lockCall(common); ok {
+		if method == "RLock" || method == "Lock" {
+			return []ssaAcquisition{{lock: lock, method: method, stack: []callFrame{newFrame(method, common.Pos())}}}
+		}
+		return nil
+	}
+	if common.IsInvoke() { // check every implementation the call could be dispatched to
+		for _, f := range s.locks.decls.impls.of(common.Value.Type(), common.Method) {
+			// instantiated methods have no function of their own until built; the generic body holds the summary
+			if callee := s.prog.FuncValue(f.Origin()); callee != nil {
+				locks = append(locks, s.calleeLocks(callee, common, newFrame(f.FullName(), common.Pos()))...)
+			}
+		}
+		return locks
+	}
+	if callee := common.StaticCallee(); callee != nil {
+		frame := newFrame(ssaCalleeID(callee), common.Pos())
+		return append(s.calleeLocks(callee, common, frame), s.invokedClosureLocks(callee, common, frame)...)
+	}
+	return nil
+}
+
+// invokedClosureLocks returns the locks acquired by the functions passed to callee that it calls before returning, if
+// it is one of the inPlaceCallers, rooted at values of the calling function
+func (s *ssaSummaries) invokedClosureLocks(callee *ssa.Function, common *ssa.CallCommon, frame callFrame) (locks []ssaAcquisition) {
+	for _, closure := range invokedClosures(callee, common) {
+		locks = append(locks, s.calleeLocks(closure.fn, closure.common, frame)...)
+	}
+	return locks
+}
+
+// invokedClosure is a function passed to one of the inPlaceCallers, with the call binding it to the free variables of
+// the closure, like a call to it
+type invokedClosure struct {
+	fn     *ssa.Function
+	common *ssa.CallCommon
+}
+
+// invokedClosures returns the functions passed to callee by common that callee calls before returning, if it is one of
+// the inPlaceCallers
+func invokedClosures(callee *ssa.Function, common *ssa.CallCommon) (closures []invokedClosure) {
+	obj, ok := callee.Object().(*types.Func)
+	if !ok {
+		return nil
+	}
+	args := common.Args
+	if callee.Signature.Recv() != nil && len(args) > 0 { // the receiver is the first argument of a static method call
+		args = args[1:]
+	}
+	for _, index := range inPlaceCallers[obj.Origin().FullName()] {
+		if index >= len(args) {
+			continue
+		}
+		switch fn := args[index].(type) {
+		case *ssa.MakeClosure:
+			closures = append(closures, invokedClosure{fn.Fn.(*ssa.Function), &ssa.CallCommon{Value: fn}})
+		case *ssa.Function:
+			closures = append(closures, invokedClosure{fn, &ssa.CallCommon{Value: fn}})
+		}
+	}
+	return closures
+}
+
+// calleeLocks returns the locks acquired by callee when it is called by common, rooted at values of the calling function
+func (s *ssaSummaries) calleeLocks(callee *ssa.Function, common *ssa.CallCommon, frame callFrame) (locks []ssaAcquisition) {
+	if callee.Blocks != nil { // declared in the package being analyzed
+		for _, l := range s.done[callee] {
+			if lock, ok := translateSSALock(l.lock, callee, common); ok {
+				method, lock := lock.view(l.method)
+				locks = append(locks, ssaAcquisition{lock: lock, method: method, stack: append([]callFrame{frame}, l.stack...)})
+			}
+		}
+		return locks
+	}
+	obj := callee.Object()
+	if obj == nil {
+		return nil
+	}
+	for _, l := range s.locks.importedLocks(obj) {
+		if lock, ok := factLock(l, callee, common); ok {
+			lock.stack = append([]callFrame{frame}, lock.stack...)
+			locks = append(locks, lock)
+		}
+	}
+	return locks
+}
+
+// factLock roots the lock l, read from the lockFact of callee or from the summaries of the package, at the arguments
+// of the call common
+func factLock(l lockAcquisition, callee *ssa.Function, common *ssa.CallCommon) (ssaAcquisition, bool) {
+	lock := ssaLock{rootKey: l.Global}
+	if l.Global == "" { // rooted at the receiver or a parameter, which callArgs lists first if there is one
+		index := l.Param
+		if callee.Signature.Recv() != nil {
+			index++
+		}
+		args := callArgs(common)
+		if index < 0 || index >= len(args) {
+			return ssaAcquisition{}, false
+		}
+		lock = lockOf(args[index])
+	}
+	last := len(l.Path) - 1
+	method, lock := lock.join(factIndices(l.Path[:last], callee, common)).view(l.Path[last])
+	return ssaAcquisition{lock: lock, method: method, stack: l.Stack}, true
+}
+
+// helperLocks roots the locks of the helper callee, taken from its lockHelper by effect, at the arguments of the call
+// common. Calls to functions that are not helpers have no effect.
+func (s *ssaSummaries) helperLocks(callee *ssa.Function, common *ssa.CallCommon, effect func(lockHelper) []summaryLock) (locks []ssaAcquisition) {
+	funcDec := s.locks.decls.of(callee.Object())
+	if funcDec == nil || funcDec.Body == nil {
+		return nil
+	}
+	for _, l := range effect(s.locks.helpers[funcDec.Body]) {
+		if l, ok := s.locks.acquisition(funcDec, l); ok {
+			if lock, ok := factLock(l, callee, common); ok {
+				locks = append(locks, lock)
+			}
+		}
+	}
+	return locks
+}
+
+// helperEvents returns the locks released and acquired by common through a helper (see lockHelper), rooted at values
+// of the calling function: the locks a helper called by common releases and returns holding, or the locks released by
+// calling the function returned by a helper, like unlock() with unlock := s.rlock(). Released locks are returned with
+// their unlock method.
+func (s *ssaSummaries) helperEvents(common *ssa.CallCommon) (releases, acquires []ssaAcquisition) {
+	if inner, ok := common.Value.(*ssa.Call); ok { // s.rlock()()
+		if callee := inner.Call.StaticCallee(); callee != nil {
+			return s.helperLocks(callee, inner.Common(), func(h lockHelper) []summaryLock { return h.returns }), nil
+		}
+		return nil, nil
+	}
+	callee := common.StaticCallee()
+	if callee == nil {
+		return nil, nil
+	}
+	releases = s.helperLocks(callee, common, func(h lockHelper) []summaryLock { return h.releases })
+	acquires = s.helperLocks(callee, common, func(h lockHelper) []summaryLock { return h.acquires })
+	return releases, acquires
+}
+
+// returnsHolding returns true if fn is a helper returning with lock, acquired with method, held for its caller
+func (s *ssaSummaries) returnsHolding(fn *ssa.Function, lock ssaLock, method string) bool {
+	if fn.Object() == nil {
+		return false
+	}
+	self := &ssa.CallCommon{Value: fn} // the locks of fn rooted at its own parameters
+	for _, p := range fn.Params {
+		self.Args = append(self.Args, p)
+	}
+	for _, l := range s.helperLocks(fn, self, func(h lockHelper) []summaryLock { return h.acquires }) {
+		if l.method == method && l.lock.key() == lock.key() {
+			return true
+		}
+	}
+	return false
+}
+
+// lockCall returns the name of the lock method called by common along with the lock it is called on.
+// ok is false if common is not a call to RLock, Lock, RUnlock, Unlock or one of the tryLockMethods.
+func lockCall(common *ssa.CallCommon) (method string, lock ssaLock, ok bool) {
+	switch {
+	case common.IsInvoke():
+		method = common.Method.Name()
+	case common.StaticCallee() != nil && common.StaticCallee().Signature.Recv() != nil:
+		method = common.StaticCallee().Name()
+	default:
+		return "", ssaLock{}, false
+	}
+	switch method {
+	case "RLock", "Lock", "RUnlock", "Unlock", "TryRLock", "TryLock":
+		method, lock = lockOf(callArgs(common)[0]).view(method)
+		return method, lock, true
+	}
+	return "", ssaLock{}, false
+}
+
+// callArgs returns the arguments of common with the receiver first, for both static and interface method calls
+func callArgs(common *ssa.CallCommon) []ssa.Value {
+	if common.IsInvoke() {
+		return append([]ssa.Value{common.Value}, common.Args...)
+	}
+	return common.Args
+}
+
+// translateSSALock roots a lock found in callee at the arguments of the call to it
+func translateSSALock(l ssaLock, callee *ssa.Function, common *ssa.CallCommon) (ssaLock, bool) {
+	l = translateIndices(l, callee, common)
+	switch l.root.(type) {
+	case *ssa.Parameter, *ssa.FreeVar:
+		if arg := callerValue(l.root, callee, common); arg != nil {
+			return lockOf(arg).join(ssaLock{path: l.path, indices: l.indices}), true
+		}
+	case *ssa.Global, nil:
+		return l, true
+	}
+	return ssaLock{}, false
+}
+
+// callerValue returns the value of the calling function the parameter or free variable v of callee is bound to by
+// common, or nil if v is neither
+func callerValue(v ssa.Value, callee *ssa.Function, common *ssa.CallCommon) ssa.Value {
+	switch v := v.(type) {
+	case *ssa.Parameter:
+		args := callArgs(common)
+		for i, p := range callee.Params {
+			if p == v && i < len(args) {
+				return args[i]
+			}
+		}
+	case *ssa.FreeVar:
+		if closure, ok := common.Value.(*ssa.MakeClosure); ok {
+			for i, fv := range callee.FreeVars {
+				if fv == v {
+					return closure.Bindings[i]
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// translateIndices replaces the indices of l that are parameters or free variables of callee with the values of the
+// calling function they are bound to. Other indices cannot be written in the caller and become unknown.
+func translateIndices(l ssaLock, callee *ssa.Function, common *ssa.CallCommon) ssaLock {
+	if len(l.indices) == 0 {
+		return l
+	}
+	path := append([]string(nil), l.path...)
+	indices := append([]ssa.Value(nil), l.indices...)
+	i := 0
+	for j, elem := range path {
+		if !isIndex(elem) {
+			continue
+		}
+		if v := indices[i]; v != nil {
+			path[j], indices[i] = unknownIndex, nil
+			if arg := callerValue(v, callee, common); arg != nil {
+				path[j], indices[i] = ssaIndexKey(arg), arg
+				if isConstValue(arg) {
+					indices[i] = nil
+				}
+			}
+		}
+		i++
+	}
+	l.path, l.indices = path, indices
+	return l
+}
+
+// factIndices returns the lock selected by path, read from the lockFact of callee, with the parameters path uses as
+// indices replaced with the arguments of the call (see factPath)
+func factIndices(path []string, callee *ssa.Function, common *ssa.CallCommon) (l ssaLock) {
+	args := callArgs(common)
+	if callee.Signature.Recv() != nil && len(args) > 0 {
+		args = args[1:]
+	}
+	for _, elem := range path {
+		if !isIndex(elem) {
+			l = l.extend(elem)
+			continue
+		}
+		var arg ssa.Value
+		if index, ok := factParam(elem); ok {
+			if index < len(args) {
+				arg = args[index]
+			} else {
+				elem = unknownIndex
+			}
+		}
+		if arg != nil {
+			l = l.index(arg)
+		} else { // a constant or unknown index
+			l = l.extend(elem)
+			l.indices = append(l.indices, nil)
+		}
+	}
+	return l
+}
+
+// ssaCalleeID returns the same ID getCallInfo would use for fn
+func ssaCalleeID(fn *ssa.Function) string {
+	if obj := fn.Object(); obj != nil {
+		return obj.Id()
+	}
+	return fn.Name()
+}
+
+func (l ssaAcquisition) key() heldKey {
+	return heldKey{l.lock.key(), l.method}
+}
+
+// addSSALock appends l to locks unless the same lock was already acquired with the same method
+func addSSALock(locks []ssaAcquisition, l ssaAcquisition) []ssaAcquisition {
+	for _, found := range locks {
+		if found.key() == l.key() {
+			return locks
+		}
+	}
+	return append(locks, l)
+}
+
+// heldKey identifies a held lock by the key of the lock and the method it was acquired with
+type heldKey struct {
+	lock   string
+	method string
+}
+
+// ssaLockset holds the locks that may be held at a point in an SSA function
+type ssaLockset map[heldKey]ssaLock
+
+func (l ssaLockset) with(lock ssaLock, method string) ssaLockset {
+	ret := make(ssaLockset, len(l)+1)
+	for k, v := range l {
+		ret[k] = v
+	}
+	ret[heldKey{lock.key(), method}] = lock
+	return ret
+}
+
+func (l ssaLockset) without(lock ssaLock, method string) ssaLockset {
+	ret := make(ssaLockset, len(l))
+	for k, v := range l {
+		if k != (heldKey{lock.key(), method}) {
+			ret[k] = v
+		}
+	}
+	return ret
+}
+
+// union returns the locks held in either l or l2, and whether l2 held any lock missing from l
+func (l ssaLockset) union(l2 ssaLockset) (ssaLockset, bool) {
+	changed := false
+	for k, lock := range l2 {
+		if _, found := l[k]; !found {
+			l = l.with(lock, k.method)
+			changed = true
+		}
+	}
+	return l, changed
+}
+
+// intersect returns the locks in both l and l2, and whether l held any lock missing from l2
+func (l ssaLockset) intersect(l2 ssaLockset) (ssaLockset, bool) {
+	changed := false
+	for k, lock := range l {
+		if _, found := l2[k]; !found {
+			l = l.without(lock, k.method)
+			changed = true
+		}
+	}
+	return l, changed
+}
+
+// nestedError returns the key of the held lock lock may be, along with the error to report if lock is acquired with
+// method while l is held. The error is nil if lock is not held. A lock held with the same key is preferred over the
+// locks it may alias, which are picked in the order of their keys.
+func (l ssaLockset) nestedError(lock ssaLock, method string) (held heldKey, err error) {
+	for _, heldMethod := range []string{"RLock", "Lock"} {
+		var found []heldKey
+		for k, heldLock := range l {
+			if k.method == heldMethod && heldLock.aliases(lock) {
+				found = append(found, k)
+			}
+		}
+		if len(found) == 0 {
+			continue
+		}
+		sort.Slice(found, func(i, j int) bool {
+			if same := found[i].lock == lock.key(); same != (found[j].lock == lock.key()) {
+				return same
+			}
+			return found[i].lock < found[j].lock
+		})
+		return found[0], nestedLockErrors[heldMethod][method]
+	}
+	return heldKey{}, nil
+}
+
+// forget returns a copy of l without the locks drop returns true for
+func (l ssaLockset) forget(drop func(ssaLock) bool) ssaLockset {
+	ret := make(ssaLockset, len(l))
+	for k, v := range l {
+		if !drop(v) {
+			ret[k] = v
+		}
+	}
+	return ret
+}
+
+// runSSA is the SSA based engine of run. It checks every source function for nested RLocks using a forward dataflow
+// analysis over its basic blocks.
+func runSSA(pass *analysis.Pass, locks *lockSummaries) (interface{}, error) {
+	ssaInput, ok := pass.ResultOf[buildssa.Analyzer].(*buildssa.SSA)
+	if !ok {
+		return nil, errors.New("analyzer is not type *buildssa.SSA")
+	}
+	summaries := newSSASummaries(pass, locks, ssaInput)
+	for _, fn := range ssaInput.SrcFuncs {
+		flow := &ssaLockFlow{
+			summaries:  summaries,
+			in:         make(map[*ssa.BasicBlock]ssaLockState),
+			acquiredAt: make(map[heldKey]token.Pos),
+			releasedAt: make(map[heldKey]token.Pos),
+			deferredAt: make(map[heldKey]token.Pos),
+		}
+		flow.solve(fn)
+		flow.report(fn)
+	}
+	return nil, nil
+}
+
+// ssaLockState is the SSA counterpart of lockState. Deferred and released locks are keyed by the method of the lock
+// they release.
+type ssaLockState struct {
+	held           ssaLockset
+	deferred       ssaLockset
+	alwaysDeferred ssaLockset // the deferred locks released on every path
+	released       ssaLockset
+	reacquired     ssaLockset
+}
+
+// union returns the state holding the locks of both s and s2, and whether s2 held any lock missing from s or lacked a
+// lock always deferred in s
+func (s ssaLockState) union(s2 ssaLockState) (ssaLockState, bool) {
+	var changed [5]bool
+	s.held, changed[0] = s.held.union(s2.held)
+	s.deferred, changed[1] = s.deferred.union(s2.deferred)
+	s.alwaysDeferred, changed[2] = s.alwaysDeferred.intersect(s2.alwaysDeferred)
+	s.released, changed[3] = s.released.union(s2.released)
+	s.reacquired, changed[4] = s.reacquired.union(s2.reacquired)
+	return s, changed != [5]bool{}
+}
+
+// forget returns a copy of s without the locks drop returns true for
+func (s ssaLockState) forget(drop func(ssaLock) bool) ssaLockState {
+	return ssaLockState{
+		held:           s.held.forget(drop),
+		deferred:       s.deferred.forget(drop),
+		alwaysDeferred: s.alwaysDeferred.forget(drop),
+		released:       s.released.forget(drop),
+		reacquired:     s.reacquired.forget(drop),
+	}
+}
+
+// ssaLockFlow is the SSA counterpart of lockFlow. The positions of the first call acquiring, releasing or deferring the
+// release of each lock are kept for reports.
+type ssaLockFlow struct {
+	summaries  *ssaSummaries
+	in         map[*ssa.BasicBlock]ssaLockState // missing blocks have not been reached (yet)
+	acquiredAt map[heldKey]token.Pos
+	releasedAt map[heldKey]token.Pos
+	deferredAt map[heldKey]token.Pos
+
+	// loops maps the blocks of every loop of the function to a block identifying the loop. The locks selected with an
+	// index defined in a loop are forgotten once the loop is left, like the variables declared by a loop.
+	loops map[*ssa.BasicBlock]*ssa.BasicBlock
+}
+
+func (f *ssaLockFlow) solve(fn *ssa.Function) {
+	if len(fn.Blocks) == 0 {
+		return
+	}
+	f.loops = make(map[*ssa.BasicBlock]*ssa.BasicBlock)
+	succs := func(b *ssa.BasicBlock) []*ssa.BasicBlock { return b.Succs }
+	for _, scc := range stronglyConnected(fn.Blocks, succs) {
+		if len(scc) == 1 && !slices.Contains(scc[0].Succs, scc[0]) {
+			continue
+		}
+		for _, b := range scc {
+			f.loops[b] = scc[len(scc)-1]
+		}
+	}
+	entry := fn.Blocks[0]
+	f.in[entry] = ssaLockState{}
+	work := []*ssa.BasicBlock{entry}
+	queued := map[*ssa.BasicBlock]bool{entry: true}
+	for len(work) > 0 {
+		b := work[0]
+		work = work[1:]
+		queued[b] = false
+		out := f.transfer(b, f.in[b], false)
+		for i, succ := range b.Succs {
+			merged, changed := f.branch(b, i, out), true
+			if old, reached := f.in[succ]; reached {
+				merged, changed = old.union(merged)
+			}
+			if !changed {
+				continue
+			}
+			f.in[succ] = merged
+			if !queued[succ] {
+				queued[succ] = true
+				work = append(work, succ)
+			}
+		}
+	}
+}
+
+func (f *ssaLockFlow) report(fn *ssa.Function) {
+	for _, b := range fn.Blocks {
+		if state, reached := f.in[b]; reached {
+			f.transfer(b, state, true)
+		}
+	}
+}
+
+// transfer applies the calls in b to state and returns the state at the end of b. Deferred calls are
+// separate instructions, so they are never applied before the function returns. The releases they make are
+// recorded instead, and checked against the locks still held at every return.
+func (f *ssaLockFlow) transfer(b *ssa.BasicBlock, state ssaLockState, report bool) ssaLockState {
+	pass := f.summaries.pass
+	state = state.forget(func(l ssaLock) bool { // indices of the loops that were left
+		for _, index := range l.indices {
+			if instr, ok := index.(ssa.Instruction); ok && f.loops[instr.Block()] != nil && f.loops[instr.Block()] != f.loops[b] {
+				return true
+			}
+		}
+		return false
+	})
+	for _, instr := range b.Instrs {
+		if v, ok := instr.(ssa.Value); ok { // locks selected with a previous value of v select another element
+			state = state.forget(func(l ssaLock) bool { return l.indexedBy(v) })
+		}
+		switch instr := instr.(type) {
+		case *ssa.Defer:
+			for _, release := range f.summaries.deferredReleases(instr.Common()) {
+				state.deferred = state.deferred.with(release.lock, release.method)
+				state.alwaysDeferred = state.alwaysDeferred.with(release.lock, release.method)
+				firstPos(f.deferredAt, heldKey{release.lock.key(), release.method}, instr.Pos())
+			}
+			continue
+		case *ssa.Return:
+			if report {
+				f.reportExit(instr, state)
+			}
+			continue
+		}
+		call, ok := instr.(*ssa.Call)
+		if !ok {
+			continue
+		}
+		method, lock, isLockCall := lockCall(call.Common())
+		switch {
+		case isLockCall && (method == "RLock" || method == "Lock"):
+			if held, err := state.held.nestedError(lock, method); err != nil && report {
+				reportFrames(pass, call.Pos(), err.Error(), []callFrame{
+					newFrame(held.method, f.acquiredAt[held]),
+					newFrame(method, call.Pos()),
+				})
+			}
+			state = f.acquire(state, lock, method, call.Pos())
+		case isLockCall && tryLockMethods[method] != "": // the lock is acquired on the branch taken if it succeeds
+			if held, err := state.held.nestedError(lock, method); err != nil && report {
+				reportFrames(pass, call.Pos(), err.Error(), []callFrame{
+					newFrame(held.method, f.acquiredAt[held]),
+					newFrame(method, call.Pos()),
+				})
+			}
+		case isLockCall && (method == "RUnlock" || method == "Unlock"):
+			state = f.release(call, lock, releasedMethods[method], state, report)
+		default:
+			if report && len(state.held) > 0 {
+				f.reportNested(call, state.held)
+			}
+			releases, acquires := f.summaries.helperEvents(call.Common()) // s.lockRead(), unlock()
+			for _, release := range releases {
+				state = f.release(call, release.lock, releasedMethods[release.method], state, false)
+			}
+			for _, acquire := range acquires {
+				state = f.acquire(state, acquire.lock, acquire.method, call.Pos())
+			}
+		}
+	}
+	return state
+}
+
+// acquire applies a call at pos acquiring lock with method to state
+func (f *ssaLockFlow) acquire(state ssaLockState, lock ssaLock, method string, pos token.Pos) ssaLockState {
+	if _, found := state.held[heldKey{lock.key(), method}]; found {
+		state.reacquired = state.reacquired.with(lock, method)
+	}
+	firstPos(f.acquiredAt, heldKey{lock.key(), method}, pos)
+	state.held = state.held.with(lock, method)
+	state.released = state.released.without(lock, method)
+	return state
+}
+
+// release applies an unlock call releasing lock, acquired with lockMethod, to state. If report is set, it reports the
+// call when the lock may already have been released or was acquired with the other lock method.
+func (f *ssaLockFlow) release(call *ssa.Call, lock ssaLock, lockMethod string, state ssaLockState, report bool) ssaLockState {
+	pass := f.summaries.pass
+	key := heldKey{lock.key(), lockMethod}
+	otherKey := heldKey{lock.key(), otherLockMethod(lockMethod)}
+	if _, found := state.reacquired[key]; found {
+		state.reacquired = state.reacquired.without(lock, lockMethod)
+		return state
+	}
+	_, held := state.held[key]
+	_, heldOther := state.held[otherKey]
+	_, released := state.released[key]
+	switch {
+	case report && released:
+		reportFrames(pass, call.Pos(), errUnlockNotHeld.Error(), []callFrame{
+			newFrame(unlockMethods[lockMethod], f.releasedAt[key]),
+			newFrame(unlockMethods[lockMethod], call.Pos()),
+		})
+	case report && !held && heldOther:
+		reportFrames(pass, call.Pos(), mismatchedUnlockErrors[unlockMethods[lockMethod]].Error(), []callFrame{
+			newFrame(otherKey.method, f.acquiredAt[otherKey]),
+			newFrame(unlockMethods[lockMethod], call.Pos()),
+		})
+	}
+	if !held { // a mismatched release still releases the lock
+		state.held = state.held.without(lock, otherKey.method)
+	}
+	firstPos(f.releasedAt, key, call.Pos())
+	state.held = state.held.without(lock, lockMethod)
+	state.released = state.released.with(lock, lockMethod)
+	return state
+}
+
+// firstPos records pos for key unless a position was already recorded
+func firstPos(positions map[heldKey]token.Pos, key heldKey, pos token.Pos) {
+	if _, found := positions[key]; !found {
+		positions[key] = pos
+	}
+}
+
+// deferredReleases returns the locks released by a deferred call, either by the call itself, by the unlock calls
+// of a deferred closure or through a helper (see lockHelper). Each release is returned with the method of the lock it
+// releases.
+func (s *ssaSummaries) deferredReleases(common *ssa.CallCommon) (releases []ssaAcquisition) {
+	if method, lock, ok := lockCall(common); ok {
+		if releasedMethods[method] != "" {
+			releases = append(releases, ssaAcquisition{lock: lock, method: releasedMethods[method]})
+		}
+		return releases
+	}
+	if helperReleases, _ := s.helperEvents(common); len(helperReleases) > 0 { // defer s.unlockRead(), defer unlock()
+		for _, release := range helperReleases {
+			releases = append(releases, ssaAcquisition{lock: release.lock, method: releasedMethods[release.method]})
+		}
+		return releases
+	}
+	callee := common.StaticCallee()
+	if callee == nil || callee.Parent() == nil {
+		return nil
+	}
+	for _, b := range callee.Blocks {
+		for _, instr := range b.Instrs {
+			call, ok := instr.(*ssa.Call)
+			if !ok {
+				continue
+			}
+			method, lock, ok := lockCall(call.Common())
+			if !ok || releasedMethods[method] == "" {
+				continue
+			}
+			if lock, ok := translateSSALock(lock, callee, common); ok {
+				method, lock := lock.view(method)
+				releases = append(releases, ssaAcquisition{lock: lock, method: releasedMethods[method]})
+			}
+		}
+	}
+	return releases
+}
+
+// reportExit reports ret for every lock in state that is still held without a deferred call releasing it, and for
+// every release deferred on every path of a lock that may already have been released
+func (f *ssaLockFlow) reportExit(ret *ssa.Return, state ssaLockState) {
+	pass := f.summaries.pass
+	pos := ret.Pos()
+	if pos == token.NoPos { // implicit return at the end of the body
+		switch syntax := ret.Parent().Syntax().(type) {
+		case *ast.FuncDecl:
+			pos = syntax.Body.Rbrace
+		case *ast.FuncLit:
+			pos = syntax.Body.Rbrace
+		}
+	}
+	for _, k := range sortedKeys(state.held, f.acquiredAt) {
+		if _, released := state.deferred[k]; released {
+			continue
+		}
+		if f.summaries.returnsHolding(ret.Parent(), state.held[k], k.method) { // like s.mu.RLock() in s.lockRead()
+			continue
+		}
+		reportFrames(pass, pos, errLockLeak.Error(), []callFrame{
+			newFrame(k.method, f.acquiredAt[k]),
+			newFrame("return", pos),
+		})
+	}
+	for _, k := range sortedKeys(state.alwaysDeferred, f.deferredAt) {
+		if _, released := state.released[k]; !released {
+			continue
+		}
+		reportFrames(pass, pos, errDeferredUnlockNotHeld.Error(), []callFrame{
+			newFrame("defer "+unlockMethods[k.method], f.deferredAt[k]),
+			newFrame(unlockMethods[k.method], f.releasedAt[k]),
+			newFrame("return", pos),
+		})
+	}
+}
+
+// sortedKeys returns the keys of l ordered by their positions in positions, like lockset.sorted, so reports are made
+// in a stable order
+func sortedKeys(l ssaLockset, positions map[heldKey]token.Pos) []heldKey {
+	keys := make([]heldKey, 0, len(l))
+	for k := range l {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if positions[keys[i]] != positions[keys[j]] {
+			return positions[keys[i]] < positions[keys[j]]
+		}
+		if keys[i].lock != keys[j].lock {
+			return keys[i].lock < keys[j].lock
+		}
+		return keys[i].method < keys[j].method
+	})
+	return keys
+}
+
+// reportNested reports call if any function it calls acquires a lock in held, preferring nested RLocks over nested Locks
+func (f *ssaLockFlow) reportNested(call *ssa.Call, held ssaLockset) {
+	locks := f.summaries.callLocks(call)
+	for _, method := range []string{"RLock", "Lock"} {
+		for _, l := range locks {
+			if heldLock, err := held.nestedError(l.lock, l.method); err != nil && l.method == method {
+				pass := f.summaries.pass
+				outer := newFrame(heldLock.method, f.acquiredAt[heldLock])
+				reportFrames(pass, call.Pos(), err.Error(), append([]callFrame{outer}, l.stack...))
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,740 @@
+package sa
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"reflect"
+	"slices"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// maxPathLength bounds the access paths kept in summaries, so recursive functions walking a linked structure
+// (f(x) calling f(x.next)) reach a fixpoint
+const maxPathLength = 8
+
+// summaryLock is a lock acquired by a function, identified by its access path, rooted at the receiver, a parameter or
+// a package-level variable once the summary is complete
+type summaryLock struct {
+	accessPath
+	stack []callFrame // calls leading to the acquisition, ending with the lock call itself
+}
+
+// readMethods maps the methods of a sync.Locker to the methods of a sync.RWMutex they call when the Locker is returned
+// by its RLocker method
+var readMethods = map[string]string{
+	"Lock":   "RLock",
+	"Unlock": "RUnlock",
+}
+
+// isRLocker returns true if obj is the RLocker method of sync.RWMutex
+func isRLocker(obj types.Object) bool {
+	f, ok := obj.(*types.Func)
+	return ok && f.FullName() == "(*sync.RWMutex).RLocker"
+}
+
+// embeddedFields returns the embedded fields implicitly selected by a selection of type t with the given index (see
+// types.Selection.Index), leaving out the selected field or method itself. Whether a field is embedded by value or by
+// pointer makes no difference, as the selection dereferences pointers implicitly.
+func embeddedFields(t types.Type, index []int) (fields []*types.Var) {
+	for _, i := range index[:len(index)-1] {
+		s, ok := deref(t).Underlying().(*types.Struct)
+		if !ok {
+			return fields
+		}
+		fields = append(fields, s.Field(i))
+		t = s.Field(i).Type()
+	}
+	return fields
+}
+
+// sameLocks returns true if locks and locks2 hold the same locks, acquired with the same methods, whatever the calls
+// they were found through
+func sameLocks(locks, locks2 []summaryLock) bool {
+	return sameKeys(locks, locks2, func(l summaryLock) string { return l.key })
+}
+
+// addSummaryLock appends l to locks unless the same lock was already found with the same method
+func addSummaryLock(locks []summaryLock, l summaryLock) []summaryLock {
+	for _, found := range locks {
+		if found.equal(l.accessPath) {
+			return locks
+		}
+	}
+	return append(locks, l)
+}
+
+// summariesAnalyzer summarizes the locks acquired by the functions declared in a package once, for every analyzer
+// following locks through calls, and exports them as lockFacts for the packages importing it.
+var summariesAnalyzer = &analysis.Analyzer{
+	Name:       "locksummaries",
+	Doc:        "Summarizes the locks acquired by the functions and methods of a package",
+	Requires:   []*analysis.Analyzer{declsAnalyzer},
+	Run:        runSummaries,
+	ResultType: reflect.TypeOf((*lockSummaries)(nil)),
+	FactTypes:  []analysis.Fact{new(lockFact)},
+}
+
+func runSummaries(pass *analysis.Pass) (interface{}, error) {
+	summaries := newLockSummaries(pass, pass.ResultOf[declsAnalyzer].(*funcDecls))
+	exportLockFacts(pass, summaries)
+	return summaries, nil
+}
+
+// lockSummaries holds the locks acquired by every function declared in a package, including the locks acquired by the
+// functions they call. Summaries are rooted at the receiver, the parameters and package-level variables.
+type lockSummaries struct {
+	pass  *analysis.Pass
+	decls *funcDecls
+	done  map[*ast.FuncDecl][]summaryLock
+	lits  map[*ast.BlockStmt]bool // function literal bodies being summarized, to stop literals calling themselves
+
+	// results holds the access path returned by every function that always returns the same path rooted at one of its
+	// parameters or at a package-level variable, so a lock selected from the result of a call can be rooted at the caller
+	results map[*ast.FuncDecl]accessPath
+
+	// invokes holds the indices of the func-valued parameters every function calls, directly or by passing them on to
+	// another function calling them, so the methods passed to it as callbacks can be followed
+	invokes map[*ast.FuncDecl][]int
+
+	// helpers holds the effect on the locks of their callers of the functions acquiring or releasing locks for them,
+	// keyed by their bodies (see lockHelper)
+	helpers map[*ast.BlockStmt]lockHelper
+
+	// targets holds the implementations every interface method call may run (see callInfo.targets), resolved once when
+	// the callees of the calling function are found
+	targets map[callSite][]*callInfo
+
+	// order holds the strongly connected components of the call graph of the package, callees first, for the
+	// summaries built on top of these ones
+	order [][]*ast.FuncDecl
+}
+
+// newLockSummaries summarizes every function declared in the package bottom-up: the strongly connected components of
+// the call graph are visited callees first, and the functions of a component are summarized until their summaries stop
+// changing, so recursive calls see the complete summary of the functions they call.
+func newLockSummaries(pass *analysis.Pass, funcs *funcDecls) *lockSummaries {
+	s := &lockSummaries{
+		pass:  pass,
+		decls: funcs,
+		done:  make(map[*ast.FuncDecl][]summaryLock),
+		lits:  make(map[*ast.BlockStmt]bool),
+
+		results: make(map[*ast.FuncDecl]accessPath),
+		invokes: make(map[*ast.FuncDecl][]int),
+		helpers: make(map[*ast.BlockStmt]lockHelper),
+		targets: make(map[callSite][]*callInfo),
+	}
+	var decls []*ast.FuncDecl
+	for _, funcDec := range funcs.list {
+		if funcDec.Body != nil {
+			decls = append(decls, funcDec)
+		}
+	}
+	s.order = stronglyConnected(decls, s.callees)
+	for _, scc := range s.order {
+		for changed := true; changed; {
+			changed = false
+			for _, funcDec := range scc {
+				if _, found := s.results[funcDec]; !found {
+					if result, ok := s.resultPath(funcDec); ok {
+						s.results[funcDec] = result
+						changed = true
+					}
+				}
+				if invoked := s.invokedParams(funcDec); !slices.Equal(invoked, s.invokes[funcDec]) {
+					s.invokes[funcDec] = invoked
+					changed = true
+				}
+				if locks := s.summarize(funcDec.Body); !sameLocks(locks, s.done[funcDec]) {
+					changed = true
+					s.done[funcDec] = locks
+				}
+				if helper := s.lockHelperOf(funcDec); !helper.equal(s.helpers[funcDec.Body]) {
+					s.helpers[funcDec.Body] = helper
+					changed = true
+				}
+			}
+		}
+	}
+	return s
+}
+
+// calls calls visit for every call made by body. Calls that are not resolved to a function are skipped along with
+// their arguments, and so are the calls made by the goroutines body starts. The calls of function literals are only
+// visited if the literal is invoked in place: called right away, like func() {...}(), or passed to a function calling
+// it before returning, like sort.Slice (see invokedLits). Literals that are stored or returned may run later, without
+// the locks held by body.
+func (s *lockSummaries) calls(body ast.Node, visit func(c *callInfo)) {
+	s.walk(body, func(node ast.Node, c *callInfo) {
+		if c != nil {
+			visit(c)
+		}
+	})
+}
+
+// walk calls visit for every node body runs in the goroutine running it, following the same function literals as
+// calls. c is the callInfo of node if it is a call resolved to a function, and nil otherwise.
+func (s *lockSummaries) walk(body ast.Node, visit func(node ast.Node, c *callInfo)) {
+	var inspect func(node ast.Node) bool
+	inspect = func(node ast.Node) bool {
+		switch stmt := node.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.GoStmt:
+			for _, e := range goEvaluated(stmt) {
+				ast.Inspect(e, inspect)
+			}
+			return false
+		case *ast.CallExpr:
+			if lit := invokedLit(stmt); lit != nil {
+				ast.Inspect(lit.Body, inspect)
+				return true
+			}
+			c := getCallInfo(s.pass.TypesInfo, stmt)
+			if c == nil {
+				return false
+			}
+			visit(node, c)
+			for _, lit := range s.invokedLits(c) {
+				ast.Inspect(lit.Body, inspect)
+			}
+			return true
+		case nil:
+			return false
+		}
+		visit(node, nil)
+		return true
+	}
+	ast.Inspect(body, inspect)
+}
+
+// callees returns the functions declared in the package that funcDec may call, including through the function
+// literals it calls
+func (s *lockSummaries) callees(funcDec *ast.FuncDecl) (callees []*ast.FuncDecl) {
+	seen := make(map[ast.Node]bool)
+	var visit func(c *callInfo)
+	visit = func(c *callInfo) {
+		if isLockMethod(c.id) {
+			return
+		}
+		if block := s.funcLitBlock(c); block != nil {
+			if !seen[block] {
+				seen[block] = true
+				s.calls(block, visit)
+			}
+			return
+		}
+		targets := s.callTargets(c)
+		for _, arg := range c.call.Args { // methods passed as callbacks may be called by the callee
+			if cb := methodCallInfo(s.pass.TypesInfo, c.call, arg); cb != nil {
+				targets = append(targets, s.callTargets(cb)...)
+			}
+		}
+		for _, callee := range targets {
+			if callee.obj.Pkg() != s.pass.Pkg {
+				continue
+			}
+			if decl := s.decls.of(callee.obj); decl != nil && decl.Body != nil && !seen[decl] {
+				seen[decl] = true
+				callees = append(callees, decl)
+			}
+		}
+	}
+	s.calls(funcDec.Body, visit)
+	return callees
+}
+
+// callSite identifies the callInfo of a call, which may also stand for a method passed to the call as a callback
+type callSite struct {
+	call     *ast.CallExpr
+	fun      *ast.SelectorExpr
+	callback bool
+}
+
+// callTargets returns c.targets, resolving the implementations of an interface method call once
+func (s *lockSummaries) callTargets(c *callInfo) []*callInfo {
+	if !c.isInterfaceCall() {
+		return []*callInfo{c}
+	}
+	site := callSite{c.call, c.fun, c.callback}
+	targets, found := s.targets[site]
+	if !found {
+		targets = c.targets(s.decls.impls)
+		s.targets[site] = targets
+	}
+	return targets
+}
+
+// funcLitBlock returns the body of the function literal c calls, either directly, through a variable or through a
+// struct field it is stored in, or nil if c does not call a function literal
+func (s *lockSummaries) funcLitBlock(c *callInfo) *ast.BlockStmt {
+	return (callHelper{call: c.call, fset: s.pass.Fset, info: s.pass.TypesInfo, funcs: s.decls}).identifyFuncLitBlock(c.selector())
+}
+
+// summarize returns the locks acquired by body with the summaries computed so far
+func (s *lockSummaries) summarize(body *ast.BlockStmt) (locks []summaryLock) {
+	s.calls(body, func(c *callInfo) {
+		switch c.id {
+		case "RLock", "Lock":
+			if p, ok := s.callPath(c.call); ok {
+				locks = addSummaryLock(locks, summaryLock{p, []callFrame{newFrame(c.id, c.call.Pos())}})
+			}
+		case "RUnlock", "Unlock":
+		default:
+			for _, l := range s.callLocks(c) {
+				locks = addSummaryLock(locks, l)
+			}
+		}
+	})
+	return locks
+}
+
+// callLocks returns the locks acquired by the functions c may call, rooted at the variables of the calling function
+func (s *lockSummaries) callLocks(c *callInfo) (locks []summaryLock) {
+	if isLockMethod(c.id) {
+		return nil
+	}
+	if block := s.funcLitBlock(c); block != nil {
+		if s.lits[block] {
+			return nil
+		}
+		s.lits[block] = true
+		defer delete(s.lits, block)
+		frame := newFrame(c.name(), c.call.Pos())
+		for _, l := range s.summarize(block) { // a local function literal shares the variables of the caller
+			l.stack = append([]callFrame{frame}, l.stack...)
+			locks = addSummaryLock(locks, l)
+		}
+		return locks
+	}
+	for _, callee := range s.callTargets(c) {
+		frame := newFrame(callee.name(), c.call.Pos())
+		for _, l := range s.calleeLocks(callee) {
+			l.stack = append([]callFrame{frame}, l.stack...)
+			locks = addSummaryLock(locks, l)
+		}
+		for _, l := range s.callbackLocks(callee) {
+			l.stack = append([]callFrame{frame}, l.stack...)
+			locks = addSummaryLock(locks, l)
+		}
+	}
+	return locks
+}
+
+// callbackLocks returns the locks acquired by the methods passed to the function called by c as callbacks, if the
+// function calls them
+func (s *lockSummaries) callbackLocks(c *callInfo) (locks []summaryLock) {
+	args := c.args()
+	for _, index := range s.invokes[s.decls.of(c.obj)] {
+		if index >= len(args) {
+			continue
+		}
+		cb := methodCallInfo(s.pass.TypesInfo, c.call, args[index])
+		if cb == nil {
+			continue
+		}
+		switch cb.id {
+		case "RLock", "Lock": // run(mu.RLock)
+			if p, ok := s.exprPath(cb.fun); ok {
+				locks = addSummaryLock(locks, summaryLock{p, []callFrame{newFrame(cb.id, cb.fun.Pos())}})
+			}
+		default:
+			for _, l := range s.callLocks(cb) {
+				locks = addSummaryLock(locks, l)
+			}
+		}
+	}
+	return locks
+}
+
+// invokedParams returns the indices of the func-valued parameters funcDec calls, in increasing order, with the
+// parameters invoked by the functions it calls as they are known so far
+func (s *lockSummaries) invokedParams(funcDec *ast.FuncDecl) (invoked []int) {
+	info := s.pass.TypesInfo
+	add := func(e ast.Expr) {
+		id, ok := ast.Unparen(e).(*ast.Ident)
+		if !ok {
+			return
+		}
+		index, ok := paramIndex(info, funcDec, info.Uses[id])
+		if !ok || index < 0 {
+			return
+		}
+		for _, found := range invoked {
+			if found == index {
+				return
+			}
+		}
+		invoked = append(invoked, index)
+	}
+	s.calls(funcDec.Body, func(c *callInfo) {
+		if _, isVar := c.obj.(*types.Var); isVar {
+			add(c.call.Fun)
+			return
+		}
+		for _, callee := range s.callTargets(c) {
+			for _, index := range s.invokes[s.decls.of(callee.obj)] {
+				if index < len(c.call.Args) {
+					add(c.call.Args[index])
+				}
+			}
+		}
+	})
+	slices.Sort(invoked)
+	return invoked
+}
+
+// importedLocks returns the locks acquired by the function obj of another package, read from its lockFact. The pass
+// of the summaries is the one lockFacts are imported from: the analyzers requiring summariesAnalyzer do not own them.
+func (s *lockSummaries) importedLocks(obj types.Object) []lockAcquisition {
+	var fact lockFact
+	if !s.pass.ImportObjectFact(obj, &fact) {
+		return nil
+	}
+	return fact.Locks
+}
+
+// calleeLocks returns the locks acquired by the function called by c, rooted at the variables of the calling function.
+// The current package is looked at before imported facts.
+func (s *lockSummaries) calleeLocks(c *callInfo) (locks []summaryLock) {
+	if c.obj.Pkg() != s.pass.Pkg {
+		for _, l := range s.importedLocks(c.obj) {
+			if p, ok := s.importedPath(c, l); ok {
+				locks = append(locks, summaryLock{p, l.Stack})
+			}
+		}
+		return locks
+	}
+	funcDec := s.decls.of(c.obj)
+	if funcDec == nil {
+		return nil
+	}
+	for _, l := range s.done[funcDec] {
+		if p, ok := s.translate(c, funcDec, l.accessPath); ok { // unless rooted at a local variable of the callee
+			locks = append(locks, summaryLock{p, l.stack})
+		}
+	}
+	return locks
+}
+
+// importedPath returns the access path of the lock l of the function of another package called by c, rooted at the
+// variables of the calling function. The names of the path are looked up in the types of the values they select, but
+// for the "Wait" ending the path of the Locker of a cond wait (see blockingOp).
+func (s *lockSummaries) importedPath(c *callInfo, l lockAcquisition) (accessPath, bool) {
+	var root accessPath
+	if l.Global != "" {
+		global := s.lookupGlobal(l.Global)
+		if global == nil {
+			return accessPath{}, false
+		}
+		root = newAccessPath(global, c.call.Pos())
+	} else {
+		arg, ok := s.callArg(c, l.Param)
+		if !ok || len(arg.elems)+len(l.Path) > maxPathLength {
+			return accessPath{}, false
+		}
+		root = arg
+	}
+	p := root
+	for i, name := range l.Path {
+		var ok bool
+		switch {
+		case isIndex(name):
+			p, ok = p.selectIndex(s.factIndex(c, name))
+		case i == len(l.Path)-1 && name == waitElem.name:
+			p, ok = p.extend(waitElem), true
+		default:
+			p, ok = p.selectName(name)
+		}
+		if !ok {
+			return accessPath{}, false
+		}
+	}
+	return p, len(p.elems) > 0
+}
+
+// paramIndex returns the index of the parameter of funcDec declaring obj, or -1 if obj is its receiver. ok is false if
+// obj is neither, or if it is a variadic parameter that does not match a single argument.
+func paramIndex(info *types.Info, funcDec *ast.FuncDecl, obj types.Object) (index int, ok bool) {
+	if funcDec.Recv != nil {
+		for _, name := range funcDec.Recv.List[0].Names {
+			if info.ObjectOf(name) == obj {
+				return -1, true
+			}
+		}
+	}
+	for _, field := range funcDec.Type.Params.List {
+		for _, name := range field.Names {
+			if info.ObjectOf(name) == obj {
+				_, variadic := field.Type.(*ast.Ellipsis)
+				return index, !variadic
+			}
+			index++
+		}
+		if len(field.Names) == 0 {
+			index++
+		}
+	}
+	return 0, false
+}
+
+// callArg returns the access path of the argument of c matching the parameter index, or of the receiver if index is
+// -1. The receiver of a method expression is the first argument, and the receiver of a promoted method is the embedded
+// field the method is declared on rather than the value it is selected from.
+func (s *lockSummaries) callArg(c *callInfo, index int) (accessPath, bool) {
+	if index >= 0 {
+		arg := s.argExpr(c, index)
+		if arg == nil {
+			return accessPath{}, false
+		}
+		return s.exprPath(arg)
+	}
+	sel, ok := ast.Unparen(c.selector()).(*ast.SelectorExpr)
+	if !ok {
+		return accessPath{}, false
+	}
+	selection := s.pass.TypesInfo.Selections[sel]
+	if selection == nil {
+		return accessPath{}, false
+	}
+	var recv ast.Expr
+	switch selection.Kind() {
+	case types.MethodVal: // recv.M(args...)
+		recv = sel.X
+	case types.MethodExpr: // (*T).M(recv, args...)
+		if args := c.args(); len(args) > 0 {
+			recv = args[0]
+		}
+	}
+	if recv == nil {
+		return accessPath{}, false
+	}
+	p, ok := s.exprPath(recv)
+	if !ok {
+		return accessPath{}, false
+	}
+	elems := selectionElems(selection.Recv(), selection.Index(), selection.Obj())
+	return p.extend(elems[:len(elems)-1]...), true
+}
+
+// argExpr returns the argument of c matching the parameter index, or nil if it is unknown. The receiver of a method
+// expression is the first argument, and is not matched by any parameter.
+func (s *lockSummaries) argExpr(c *callInfo, index int) ast.Expr {
+	args := c.args()
+	if sel, ok := ast.Unparen(c.selector()).(*ast.SelectorExpr); ok {
+		if selection := s.pass.TypesInfo.Selections[sel]; selection != nil && selection.Kind() == types.MethodExpr && len(args) > 0 {
+			args = args[1:]
+		}
+	}
+	if index < 0 || index >= len(args) {
+		return nil
+	}
+	return args[index]
+}
+
+// callPath returns the access path of the lock call c, found at c, or false if the function it calls is not selected
+// from a variable by a chain of selectors and indices
+func (s *lockSummaries) callPath(c *ast.CallExpr) (accessPath, bool) {
+	p, ok := s.exprPath(c.Fun)
+	if !ok || len(p.elems) == 0 {
+		return accessPath{}, false
+	}
+	return p.at(c.Pos()), true
+}
+
+// exprPath returns the access path of e, which has to be a variable followed by selectors and indices. Parentheses,
+// pointer indirections and address operators are skipped, as they refer to the same mutex, so nested.RLock(),
+// nested.p.RWMutex.RLock() and (*nested).RLock() all have the same path. A sync.Locker held in a local variable is
+// replaced with the value it was declared with, and calls to functions returning one of their parameters or a selection
+// from it are replaced with the matching argument.
+func (s *lockSummaries) exprPath(e ast.Expr) (accessPath, bool) {
+	info := s.pass.TypesInfo
+	switch e := ast.Unparen(e).(type) {
+	case *ast.Ident:
+		if value := lockerValue(info, e); value != nil { // l := mu.RLocker()
+			if p, ok := s.exprPath(value); ok {
+				return p, true
+			}
+		}
+		if v, ok := info.ObjectOf(e).(*types.Var); ok {
+			return newAccessPath(v, e.Pos()), true
+		}
+	case *ast.SelectorExpr:
+		selection, ok := info.Selections[e]
+		if !ok { // a qualified identifier, like iTypes.Shared
+			if v, ok := info.Uses[e.Sel].(*types.Var); ok {
+				return newAccessPath(v, e.Pos()), true
+			}
+			break
+		}
+		p, ok := s.exprPath(e.X)
+		if !ok {
+			break
+		}
+		return p.extend(selectionElems(selection.Recv(), selection.Index(), selection.Obj())...), true
+	case *ast.IndexExpr: // shards[i].mu.RLock()
+		if !isIndexExpr(info, e) {
+			break
+		}
+		p, ok := s.exprPath(e.X)
+		if !ok {
+			break
+		}
+		return p.extend(newIndexElem(info, e.Index)), true
+	case *ast.StarExpr:
+		return s.exprPath(e.X)
+	case *ast.UnaryExpr:
+		if e.Op == token.AND {
+			return s.exprPath(e.X)
+		}
+	case *ast.CallExpr:
+		if sel, ok := ast.Unparen(e.Fun).(*ast.SelectorExpr); ok {
+			if callee := typeutil.Callee(info, e); isRLocker(callee) {
+				p, ok := s.exprPath(sel.X)
+				if !ok {
+					break
+				}
+				return p.extend(pathElem{name: "RLocker", obj: callee, field: -1}), true
+			}
+		}
+		return s.callResult(e)
+	}
+	return accessPath{}, false
+}
+
+// lockerValue returns the value the local variable id of interface type, like a sync.Locker, was declared with, or nil
+// if id is not such a variable (see declaredValue).
+func lockerValue(info *types.Info, id *ast.Ident) ast.Expr {
+	v, ok := info.ObjectOf(id).(*types.Var)
+	if !ok || v.IsField() || !types.IsInterface(v.Type()) || (v.Pkg() != nil && v.Parent() == v.Pkg().Scope()) {
+		return nil
+	}
+	return declaredValue(id)
+}
+
+// callResult returns the access path of the value returned by call, rooted at the variables of the calling function
+func (s *lockSummaries) callResult(call *ast.CallExpr) (accessPath, bool) {
+	c := getCallInfo(s.pass.TypesInfo, call)
+	if c == nil || c.isInterfaceCall() {
+		return accessPath{}, false
+	}
+	funcDec := s.decls.of(c.obj)
+	result, ok := s.results[funcDec]
+	if !ok {
+		return accessPath{}, false
+	}
+	return s.translate(c, funcDec, result)
+}
+
+// translate substitutes the root of the access path p, found in the function funcDec called by c, with the matching
+// argument of the call, and its indices with the indices of the caller, so it is rooted at the variables of the
+// calling function. Paths rooted at package-level variables keep their root.
+func (s *lockSummaries) translate(c *callInfo, funcDec *ast.FuncDecl, p accessPath) (accessPath, bool) {
+	root := newAccessPath(p.root, c.call.Pos())
+	if !isGlobal(p.root) {
+		index, ok := paramIndex(s.pass.TypesInfo, funcDec, p.root)
+		if !ok {
+			return accessPath{}, false
+		}
+		if root, ok = s.callArg(c, index); !ok || len(root.elems)+len(p.elems) > maxPathLength {
+			return accessPath{}, false
+		}
+	}
+	return p.substitute(root, func(e pathElem) pathElem { return s.translateIndex(c, funcDec, e) }), true
+}
+
+// resultPath returns the access path funcDec returns if it has a single result, and every return statement returns the
+// same path rooted at a parameter or a package-level variable
+func (s *lockSummaries) resultPath(funcDec *ast.FuncDecl) (result accessPath, ok bool) {
+	if funcDec.Type.Results == nil || funcDec.Type.Results.NumFields() != 1 {
+		return accessPath{}, false
+	}
+	found, valid := false, true
+	ast.Inspect(funcDec.Body, func(node ast.Node) bool {
+		switch stmt := node.(type) {
+		case *ast.FuncLit: // returns from a function literal do not return from funcDec
+			return false
+		case *ast.ReturnStmt:
+			if len(stmt.Results) != 1 { // a bare return of a named result
+				valid = false
+				return false
+			}
+			p, ok := s.exprPath(stmt.Results[0])
+			if ok && !isGlobal(p.root) {
+				_, ok = paramIndex(s.pass.TypesInfo, funcDec, p.root)
+			}
+			if !ok || (found && !p.equal(result)) {
+				valid = false
+				return false
+			}
+			result, found = p, true
+		}
+		return valid
+	})
+	return result, found && valid
+}
+
+// sameKeys returns true if the summaries s and s2, which hold a single entry per key, have the same keys. The fixpoints
+// compare summaries with it, since a summary may find a lock through another call once its callees change.
+func sameKeys[T any, K comparable](s, s2 []T, key func(T) K) bool {
+	if len(s) != len(s2) {
+		return false
+	}
+	keys := make(map[K]bool, len(s))
+	for _, e := range s {
+		keys[key(e)] = true
+	}
+	for _, e := range s2 {
+		if !keys[key(e)] {
+			return false
+		}
+	}
+	return true
+}
+
+// stronglyConnected returns the strongly connected components of the graph of nodes and the edges given by succs. Every
+// component comes after the components it has edges to, so callees come before their callers (Tarjan's algorithm).
+func stronglyConnected[N comparable](nodes []N, succs func(N) []N) (sccs [][]N) {
+	index := make(map[N]int)
+	lowlink := make(map[N]int)
+	onStack := make(map[N]bool)
+	var stack []N
+	var connect func(n N)
+	connect = func(n N) {
+		index[n] = len(index)
+		lowlink[n] = index[n]
+		stack = append(stack, n)
+		onStack[n] = true
+		for _, succ := range succs(n) {
+			if _, visited := index[succ]; !visited {
+				connect(succ)
+				lowlink[n] = min(lowlink[n], lowlink[succ])
+			} else if onStack[succ] {
+				lowlink[n] = min(lowlink[n], index[succ])
+			}
+		}
+		if lowlink[n] != index[n] {
+			return
+		}
+		var scc []N
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			scc = append(scc, top)
+			if top == n {
+				break
+			}
+		}
+		sccs = append(sccs, scc)
+	}
+	for _, n := range nodes {
+		if _, visited := index[n]; !visited {
+			connect(n)
+		}
+	}
+	return sccs
+}
@@ -0,0 +1,245 @@
+package blocking
+
+import (
+	"blockingdep"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+type Queue struct {
+	mu    sync.Mutex
+	state sync.Mutex
+	cond  *sync.Cond
+	items []int
+	ch    chan int
+	wg    sync.WaitGroup
+	conn  net.Conn
+}
+
+func newQueue() *Queue {
+	q := &Queue{ch: make(chan int)}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+func (q *Queue) sleepLocked() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	time.Sleep(time.Second) // want `found blocking operation while holding lock: time.Sleep`
+}
+
+// the lock is released before sleeping
+func (q *Queue) sleepUnlocked() {
+	q.mu.Lock()
+	q.items = nil
+	q.mu.Unlock()
+	time.Sleep(time.Second)
+}
+
+func (q *Queue) send(v int) {
+	q.mu.Lock()
+	q.ch <- v // want `found blocking operation while holding lock: channel send`
+	q.mu.Unlock()
+}
+
+func (q *Queue) receive() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return <-q.ch // want `found blocking operation while holding lock: channel receive`
+}
+
+func (q *Queue) drain() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for v := range q.ch { // want `found blocking operation while holding lock: range over channel`
+		q.items = append(q.items, v)
+	}
+}
+
+func (q *Queue) selectLocked(done chan struct{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	select {
+	case v := <-q.ch: // want `found blocking operation while holding lock: select`
+		q.items = append(q.items, v)
+	case <-done:
+	}
+}
+
+// a select with a default case never blocks
+func (q *Queue) trySend(v int) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	select {
+	case q.ch <- v:
+		return true
+	default:
+		return false
+	}
+}
+
+func (q *Queue) waitLocked() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.wg.Wait() // want `found blocking operation while holding lock: \(\*sync.WaitGroup\).Wait`
+}
+
+func (q *Queue) read(buf []byte) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.conn.Read(buf) // want `found blocking operation while holding lock: \(net.Conn\).Read`
+}
+
+func (q *Queue) load(name string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	os.ReadFile(name) // want `found blocking operation while holding lock: os.ReadFile`
+}
+
+func (q *Queue) settle() {
+	time.Sleep(time.Millisecond)
+}
+
+func (q *Queue) push(v int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.items = append(q.items, v)
+	q.settle() // want `found blocking operation while holding lock: time.Sleep`
+}
+
+func (q *Queue) flush() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	blockingdep.Flush() // want `found blocking operation while holding lock: time.Sleep`
+	_ = blockingdep.Size(q.items)
+}
+
+func (q *Queue) receiveInPlace() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	func() { // want `found blocking operation while holding lock: channel receive`
+		<-q.ch
+	}()
+}
+
+// the goroutine and the stored function literal do not run under the lock
+func (q *Queue) receiveLater() func() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	go func() {
+		<-q.ch
+	}()
+	return func() {
+		<-q.ch
+	}
+}
+
+// waiting on a cond releases the mutex it was created with
+func (q *Queue) pop() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.items) == 0 {
+		q.cond.Wait()
+	}
+	v := q.items[0]
+	q.items = q.items[1:]
+	return v
+}
+
+func (q *Queue) waitForItems() {
+	for len(q.items) == 0 {
+		q.cond.Wait()
+	}
+}
+
+func (q *Queue) popThroughHelper() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.waitForItems()
+	return q.items[0]
+}
+
+func waitLocal(ready *bool) {
+	var mu sync.Mutex
+	cond := sync.NewCond(&mu)
+	mu.Lock()
+	for !*ready {
+		cond.Wait()
+	}
+	mu.Unlock()
+}
+
+func waitOnL(c *sync.Cond, ready *bool) {
+	c.L.Lock()
+	for !*ready {
+		c.Wait()
+	}
+	c.L.Unlock()
+}
+
+// the cond releases its own lock, but not the other lock held while waiting
+func (q *Queue) waitOther(other *Queue) {
+	other.mu.Lock()
+	defer other.mu.Unlock()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.cond.Wait() // want `found blocking operation while holding lock: \(\*sync.Cond\).Wait`
+}
+
+// the cond releases the mutex it was created with, not the other mutex of the queue
+func (q *Queue) waitState() {
+	q.state.Lock()
+	defer q.state.Unlock()
+	q.cond.Wait() // want `found blocking operation while holding lock: \(\*sync.Cond\).Wait`
+}
+
+// waiting on a cond of another package releases the mutex it was created with
+func popEntry(b *blockingdep.Buffer) int {
+	b.Mu.Lock()
+	defer b.Mu.Unlock()
+	b.WaitForEntries()
+	return b.Entries[0]
+}
+
+func waitOnLOf(c *sync.Cond, ready *bool) {
+	c.L.Lock()
+	defer c.L.Unlock()
+	blockingdep.WaitOn(c, ready)
+}
+
+func (q *Queue) popEntryLocked(b *blockingdep.Buffer) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	b.Mu.Lock()
+	defer b.Mu.Unlock()
+	b.WaitForEntries() // want `found blocking operation while holding lock: \(\*sync.Cond\).Wait`
+	return b.Entries[0]
+}
+
+var retry bool
+
+// sleepRetry and retryOnce call each other, so retryOnce may sleep whichever of them is summarized first
+func sleepRetry() {
+	time.Sleep(time.Millisecond)
+	retryOnce()
+}
+
+func retryOnce() {
+	if retry {
+		sleepRetry()
+	}
+}
+
+func (q *Queue) sleepRetryLocked() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	sleepRetry() // want `found blocking operation while holding lock: time.Sleep`
+}
+
+func (q *Queue) retryLocked() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	retryOnce() // want `found blocking operation while holding lock: time.Sleep`
+}
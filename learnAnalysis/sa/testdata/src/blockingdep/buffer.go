@@ -0,0 +1,31 @@
+package blockingdep
+
+import "sync"
+
+// Buffer holds the entries written by other goroutines
+type Buffer struct {
+	Mu      sync.Mutex
+	Cond    *sync.Cond
+	Entries []int
+}
+
+// NewBuffer returns an empty Buffer
+func NewBuffer() *Buffer {
+	b := &Buffer{}
+	b.Cond = sync.NewCond(&b.Mu)
+	return b
+}
+
+// WaitForEntries waits for an entry to be written. b.Mu must be held.
+func (b *Buffer) WaitForEntries() {
+	for len(b.Entries) == 0 {
+		b.Cond.Wait()
+	}
+}
+
+// WaitOn waits until ready is set. c.L must be held.
+func WaitOn(c *sync.Cond, ready *bool) {
+	for !*ready {
+		c.Wait()
+	}
+}
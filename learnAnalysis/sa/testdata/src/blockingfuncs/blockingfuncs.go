@@ -0,0 +1,18 @@
+package blockingfuncs
+
+import (
+	"sync"
+	"time"
+)
+
+var mu sync.Mutex
+
+func fetch() {}
+
+// only the functions given with -funcs are reported
+func refresh() {
+	mu.Lock()
+	defer mu.Unlock()
+	fetch() // want `found blocking operation while holding lock: blockingfuncs.fetch`
+	time.Sleep(time.Millisecond)
+}
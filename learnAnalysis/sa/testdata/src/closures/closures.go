@@ -0,0 +1,104 @@
+package closures
+
+import (
+	"sort"
+	"sync"
+)
+
+type store struct {
+	mu    sync.RWMutex
+	init  sync.Once
+	data  map[string]int
+	hooks []func() int
+}
+
+func (s *store) get(key string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.data[key]
+}
+
+func (s *store) set(key string, v int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = v
+}
+
+// handler returns a closure reading s, which runs whenever its caller calls it
+func (s *store) handler() func() int {
+	return func() int {
+		return s.get("k")
+	}
+}
+
+func (s *store) register(f func() int) {
+	s.hooks = append(s.hooks, f)
+}
+
+func (s *store) invoke(f func()) {
+	f()
+}
+
+func (s *store) storedClosure() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, s.handler())
+}
+
+func (s *store) registered() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.register(func() int {
+		return s.get("k")
+	})
+}
+
+func (s *store) immediate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	func() { // want `found lock call while holding write lock`
+		s.data["k"] = s.get("k")
+	}()
+}
+
+func (s *store) sorted(keys []string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sort.Slice(keys, func(i, j int) bool { // want `found recursive read lock call`
+		return s.get(keys[i]) < s.get(keys[j])
+	})
+}
+
+func (s *store) once() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.init.Do(func() { // want `found lock call while holding write lock`
+		s.set("k", 1)
+	})
+}
+
+func (s *store) invoked() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.invoke(func() { // want `found lock call while holding write lock`
+		s.get("k")
+	})
+}
+
+func (s *store) readInPlace() int {
+	return func() int {
+		return s.get("k")
+	}()
+}
+
+func (s *store) throughInPlace() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.readInPlace() // want `found lock call while holding write lock`
+}
+
+func (s *store) throughHandler() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handler()
+}
@@ -0,0 +1,35 @@
+package crosspkg
+
+import "iTypes"
+
+var a *iTypes.AwesomeProtectedResource = &iTypes.AwesomeProtectedResource{}
+
+func ImportedMethod() {
+	a.RLock()
+	a.GetResource() // want `found recursive read lock call`
+	a.RUnlock()
+}
+
+func ImportedGlobal() {
+	iTypes.Shared.RLock()
+	iTypes.ReadShared() // want `found recursive read lock call`
+	iTypes.Shared.RUnlock()
+}
+
+func ImportedNestedField(h *iTypes.Holder) {
+	h.Res.RLock()
+	h.Read() // want `found recursive read lock call`
+	h.Res.RUnlock()
+}
+
+func ImportedOtherLock(h *iTypes.Holder) {
+	a.RLock()
+	h.Read()
+	a.RUnlock()
+}
+
+func ImportedParam(h *iTypes.Holder) {
+	h.Res.RLock()
+	iTypes.ReadHolder(h) // want `found recursive read lock call`
+	h.Res.RUnlock()
+}
@@ -0,0 +1,81 @@
+package embedded
+
+import "sync"
+
+type protected struct {
+	*sync.RWMutex
+	resource string
+}
+
+func (p *protected) get() string {
+	p.RLock()
+	defer p.RUnlock()
+	return p.resource
+}
+
+type nestedResource struct {
+	protected
+}
+
+type valueEmbedded struct {
+	sync.RWMutex
+}
+
+type pointerEmbedded struct {
+	*valueEmbedded
+}
+
+func promotedThenExplicit(n *nestedResource) {
+	n.RLock()
+	n.protected.RWMutex.RLock() // want `found recursive read lock call`
+	n.protected.RWMutex.RUnlock()
+	n.RUnlock()
+}
+
+func explicitThenPromoted(n *nestedResource) {
+	n.protected.RLock()
+	n.RLock() // want `found recursive read lock call`
+	n.RUnlock()
+	n.protected.RUnlock()
+}
+
+func promotedMethod(n *nestedResource) {
+	n.RWMutex.RLock()
+	n.get() // want `found recursive read lock call`
+	n.RUnlock()
+}
+
+func explicitUnlock(n *nestedResource) {
+	n.Lock()
+	n.protected.RWMutex.Unlock()
+	n.RLock()
+	n.RUnlock()
+}
+
+func throughPointerEmbedding(p *pointerEmbedded) {
+	p.RLock()
+	p.valueEmbedded.RWMutex.Lock() // want `found write lock call while holding read lock`
+	p.valueEmbedded.Unlock()
+	p.RUnlock()
+}
+
+func dereferenced(p *pointerEmbedded) {
+	(*p).valueEmbedded.RLock()
+	p.RLock() // want `found recursive read lock call`
+	p.RUnlock()
+	p.RUnlock()
+}
+
+func otherMutex(p *pointerEmbedded, v *valueEmbedded) {
+	p.RLock()
+	v.RLock()
+	v.RUnlock()
+	p.RUnlock()
+}
+
+func addressed(v *valueEmbedded) {
+	(&v.RWMutex).Lock()
+	v.Lock() // want `found lock call while holding write lock`
+	v.Unlock()
+	(&v.RWMutex).Unlock()
+}
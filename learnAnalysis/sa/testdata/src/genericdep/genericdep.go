@@ -0,0 +1,18 @@
+package genericdep
+
+import "sync"
+
+type Cache[K comparable, V any] struct {
+	Mu    sync.RWMutex
+	items map[K]V
+}
+
+func (c *Cache[K, V]) Get(k K) V {
+	c.Mu.RLock()
+	defer c.Mu.RUnlock()
+	return c.items[k]
+}
+
+func Read[K comparable, V any](c *Cache[K, V], k K) V {
+	return c.Get(k)
+}
@@ -0,0 +1,135 @@
+package generics
+
+import (
+	"genericdep"
+	"sync"
+)
+
+type cache[K comparable, V any] struct {
+	mu    sync.RWMutex
+	items map[K]V
+}
+
+func (c *cache[K, V]) get(k K) V {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.items[k]
+}
+
+func (c *cache[K, V]) set(k K, v V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items[k] = v
+}
+
+func (c *cache[K, V]) getOrSet(k K, v V) V {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if _, ok := c.items[k]; !ok {
+		c.set(k, v) // want `found write lock call while holding read lock`
+	}
+	return c.get(k) // want `found recursive read lock call`
+}
+
+func instantiated(c *cache[string, int]) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.get("k") // want `found recursive read lock call`
+}
+
+func instantiatedValue(c *cache[string, int]) {
+	set := c.set
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	set("k", 1) // want `found write lock call while holding read lock`
+}
+
+func read[K comparable, V any](c *cache[K, V], k K) V {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.items[k]
+}
+
+func throughGenericHelper(c *cache[string, int]) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return read(c, "k") // want `found recursive read lock call`
+}
+
+func throughExplicitInstance(c *cache[string, int]) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return read[string, int](c, "k") // want `found recursive read lock call`
+}
+
+type rlocker interface {
+	RLock()
+	RUnlock()
+}
+
+func readLocked[L rlocker](l L, f func()) {
+	l.RLock()
+	defer l.RUnlock()
+	f()
+}
+
+func throughConstraint(c *cache[string, int]) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	readLocked(&c.mu, func() {}) // want `found lock call while holding write lock`
+}
+
+type holder[T any] interface {
+	*T
+	lockRead()
+}
+
+type shard struct {
+	mu    sync.RWMutex
+	count int
+}
+
+func (s *shard) lockRead() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+}
+
+func visit[T any, P holder[T]](p P) {
+	p.lockRead()
+}
+
+func throughTypeParamMethod(s *shard) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	visit(s) // want `found lock call while holding write lock`
+}
+
+func otherInstance(a *cache[string, int], b *cache[int, string]) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	b.get(1)
+}
+
+func importedMethod(c *genericdep.Cache[string, int]) int {
+	c.Mu.RLock()
+	defer c.Mu.RUnlock()
+	return c.Get("k") // want `found recursive read lock call`
+}
+
+func importedHelper(c *genericdep.Cache[string, int]) int {
+	c.Mu.Lock()
+	defer c.Mu.Unlock()
+	return genericdep.Read(c, "k") // want `found lock call while holding write lock`
+}
+
+type getter interface {
+	get(k string) int
+}
+
+// cache[string, int] implements getter, so the call through the interface may run its get method
+func throughGenericImplementation(c *cache[string, int]) int {
+	var g getter = c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return g.get("k") // want `found lock call while holding write lock`
+}
@@ -0,0 +1,100 @@
+package goroutines
+
+import "sync"
+
+type store struct {
+	mu   sync.RWMutex
+	data map[string]int
+}
+
+func (s *store) get(key string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.data[key]
+}
+
+func (s *store) worker() {
+	s.mu.Lock()
+	s.data["w"]++
+	s.mu.Unlock()
+}
+
+func use(int) {}
+
+func (s *store) spawnLiteral() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	go func() {
+		s.get("k")
+	}()
+}
+
+func (s *store) spawnMethod() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	go s.worker()
+}
+
+// argumentsRunNow evaluates the arguments of the goroutine before starting it
+func (s *store) argumentsRunNow() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	go use(s.get("k")) // want `found recursive read lock call`
+}
+
+func (s *store) spawnsWorker() {
+	go s.worker()
+}
+
+func (s *store) callsSpawner() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.spawnsWorker()
+}
+
+func (s *store) waitGroup() {
+	var wg sync.WaitGroup
+	s.mu.Lock()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.worker()
+	}()
+	wg.Wait() // want `found wait for a goroutine acquiring a held lock`
+	s.mu.Unlock()
+}
+
+func (s *store) channel() {
+	done := make(chan struct{})
+	s.mu.RLock()
+	go func() {
+		s.mu.Lock()
+		s.data["c"]++
+		s.mu.Unlock()
+		close(done)
+	}()
+	<-done // want `found wait for a goroutine acquiring a held lock`
+	s.mu.RUnlock()
+}
+
+func (s *store) releasedBeforeWait() {
+	var wg sync.WaitGroup
+	s.mu.Lock()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.worker()
+	}()
+	s.mu.Unlock()
+	wg.Wait()
+}
+
+func (s *store) readersOnly() {
+	done := make(chan int)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	go func() {
+		done <- s.get("k")
+	}()
+	use(<-done)
+}
@@ -0,0 +1,104 @@
+package helpers
+
+import "sync"
+
+type store struct {
+	mu   sync.RWMutex
+	data map[string]int
+}
+
+func (s *store) lockRead() {
+	s.mu.RLock()
+}
+
+func (s *store) unlockRead() {
+	s.mu.RUnlock()
+}
+
+func (s *store) lockWrite() {
+	s.mu.Lock()
+}
+
+func (s *store) unlockWrite() {
+	s.data["writes"]++
+	s.mu.Unlock()
+}
+
+func (s *store) rlock() func() {
+	s.mu.RLock()
+	return s.mu.RUnlock
+}
+
+func (s *store) wlock() func() {
+	s.mu.Lock()
+	return func() {
+		s.mu.Unlock()
+	}
+}
+
+// lockBoth acquires locks through another helper
+func lockBoth(a, b *store) {
+	a.lockRead()
+	b.mu.RLock()
+}
+
+func (s *store) get(key string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.data[key]
+}
+
+func (s *store) readThroughHelper(key string) int {
+	s.lockRead()
+	v := s.get(key) // want `found recursive read lock call`
+	s.unlockRead()
+	return v
+}
+
+func (s *store) writeThroughHelper() {
+	s.lockWrite()
+	s.mu.RLock() // want `found lock call while holding write lock`
+	s.mu.RUnlock()
+	s.unlockWrite()
+}
+
+func (s *store) returnedUnlock(key string) int {
+	unlock := s.rlock()
+	defer unlock()
+	return s.get(key) // want `found recursive read lock call`
+}
+
+func (s *store) deferredReturnedUnlock(key string) int {
+	defer s.wlock()()
+	return s.get(key) // want `found lock call while holding write lock`
+}
+
+func (s *store) released(key string) int {
+	unlock := s.rlock()
+	unlock()
+	return s.get(key)
+}
+
+func (s *store) deferredHelper() {
+	s.lockWrite()
+	defer s.unlockWrite()
+	s.data["a"]++
+}
+
+func bothHeld(a, b *store) int {
+	lockBoth(a, b)
+	defer a.unlockRead()
+	defer b.mu.RUnlock()
+	return b.get("b") // want `found recursive read lock call`
+}
+
+// work done after acquiring the lock means the lock is not held for the caller
+func (s *store) readAndForget(key string) int {
+	s.lockRead()
+	return s.data[key] // want `found lock still held at return`
+}
+
+func (s *store) forgetsUnlock() {
+	s.lockWrite()
+	s.data["a"]++
+} // want `found lock still held at return`
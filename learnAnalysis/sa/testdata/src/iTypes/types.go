@@ -0,0 +1,38 @@
+package iTypes
+
+import "sync"
+
+type AwesomeProtectedResource struct {
+	sync.RWMutex
+	resource string
+}
+
+func (a *AwesomeProtectedResource) GetResource() string {
+	defer a.RUnlock()
+	a.RLock()
+	return a.resource
+}
+
+func (a *AwesomeProtectedResource) SetResource(r string) {
+	defer a.Unlock()
+	a.Lock()
+	a.resource = r
+}
+
+var Shared *AwesomeProtectedResource = &AwesomeProtectedResource{resource: "shared"}
+
+func ReadShared() string {
+	return Shared.GetResource()
+}
+
+type Holder struct {
+	Res AwesomeProtectedResource
+}
+
+func (h *Holder) Read() string {
+	return h.Res.GetResource()
+}
+
+func ReadHolder(h *Holder) string {
+	return h.Res.GetResource()
+}
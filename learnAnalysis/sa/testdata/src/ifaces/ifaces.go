@@ -0,0 +1,51 @@
+package ifaces
+
+import "sync"
+
+var mu sync.RWMutex
+
+type Store interface {
+	Get(key string) string
+}
+
+type cachedStore struct {
+	items map[string]string
+}
+
+func (c *cachedStore) Get(key string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return c.items[key]
+}
+
+type plainStore struct {
+	items map[string]string
+}
+
+func (p plainStore) Get(key string) string {
+	return p.items[key]
+}
+
+func readThrough(s Store) {
+	mu.RLock()
+	s.Get("key") // want `found recursive read lock call`
+	mu.RUnlock()
+}
+
+func readThroughHelper(s Store) {
+	mu.RLock()
+	get(s) // want `found recursive read lock call`
+	mu.RUnlock()
+}
+
+func get(s Store) string {
+	return s.Get("key")
+}
+
+func readPlain(p plainStore) {
+	var s Store = p
+	mu.RLock()
+	p.Get("key")
+	mu.RUnlock()
+	s.Get("key")
+}
@@ -0,0 +1,137 @@
+package indexed
+
+import "sync"
+
+type shard struct {
+	mu   sync.RWMutex
+	data map[string]int
+}
+
+type store struct {
+	shards [16]shard
+	byName map[string]*shard
+}
+
+var table [4]shard
+
+func (s *store) sameIndex(i int) {
+	s.shards[i].mu.RLock()
+	s.shards[i].mu.RLock() // want `found recursive read lock call`
+	s.shards[i].mu.RUnlock()
+	s.shards[i].mu.RUnlock()
+}
+
+func (s *store) constantIndex() {
+	s.shards[0].mu.Lock()
+	defer s.shards[0].mu.Unlock()
+	s.shards[0].mu.RLock() // want `found lock call while holding write lock`
+	s.shards[0].mu.RUnlock()
+}
+
+func (s *store) differentIndices(i, j int) {
+	s.shards[i].mu.Lock()
+	s.shards[j].mu.Lock()
+	s.shards[j].mu.Unlock()
+	s.shards[i].mu.Unlock()
+}
+
+func (s *store) differentConstants() {
+	s.shards[0].mu.Lock()
+	s.shards[1].mu.Lock()
+	s.shards[1].mu.Unlock()
+	s.shards[0].mu.Unlock()
+}
+
+// lockAll locks every shard in turn, so the index selects another shard at every iteration
+func (s *store) lockAll() {
+	for i := range s.shards {
+		s.shards[i].mu.Lock()
+	}
+	for i := range s.shards {
+		s.shards[i].mu.Unlock()
+	}
+}
+
+func (s *store) reassigned(i int) {
+	s.shards[i].mu.RLock()
+	defer s.shards[i].mu.RUnlock()
+	i++
+	s.shards[i].mu.RLock()
+	s.shards[i].mu.RUnlock()
+}
+
+// the i assigned in the block shadows the parameter, so s.shards[i] still selects the shard locked before it
+func (s *store) shadowed(i int) {
+	s.shards[i].mu.RLock()
+	defer s.shards[i].mu.RUnlock()
+	{
+		i := i + 1
+		i++
+		_ = i
+	}
+	s.shards[i].mu.RLock() // want `found recursive read lock call`
+	s.shards[i].mu.RUnlock()
+}
+
+func (s *store) leak(i int) {
+	if i < 0 {
+		return
+	}
+	s.shards[i].mu.Lock()
+} // want `found lock still held at return`
+
+func (s *store) get(i int, key string) int {
+	s.shards[i].mu.RLock()
+	defer s.shards[i].mu.RUnlock()
+	return s.shards[i].data[key]
+}
+
+func (s *store) getHashed(key string) int {
+	i := len(key) % len(s.shards)
+	s.shards[i].mu.RLock()
+	defer s.shards[i].mu.RUnlock()
+	return s.shards[i].data[key]
+}
+
+func (s *store) throughCall(i int) int {
+	s.shards[i].mu.RLock()
+	defer s.shards[i].mu.RUnlock()
+	return s.get(i, "k") // want `found recursive read lock call`
+}
+
+func (s *store) throughCallOtherIndex(i, j int) int {
+	s.shards[i].mu.RLock()
+	defer s.shards[i].mu.RUnlock()
+	return s.get(j, "k")
+}
+
+func (s *store) throughCallLocalIndex(i int) int {
+	s.shards[i].mu.RLock()
+	defer s.shards[i].mu.RUnlock()
+	return s.getHashed("k")
+}
+
+func lookup(m map[string]*shard, name string) int {
+	m[name].mu.RLock()
+	defer m[name].mu.RUnlock()
+	return m[name].data[name]
+}
+
+func (s *store) byKey(name string) int {
+	s.byName[name].mu.Lock()
+	defer s.byName[name].mu.Unlock()
+	return lookup(s.byName, name) // want `found lock call while holding write lock`
+}
+
+func readTable(i int) int {
+	table[i].mu.RLock()
+	defer table[i].mu.RUnlock()
+	return table[i].data["k"]
+}
+
+func writeTable() {
+	table[2].mu.Lock()
+	defer table[2].mu.Unlock()
+	readTable(2) // want `found lock call while holding write lock`
+	readTable(3)
+}
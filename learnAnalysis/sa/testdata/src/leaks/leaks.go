@@ -0,0 +1,87 @@
+package leaks
+
+import (
+	"errors"
+	"sync"
+)
+
+type cache struct {
+	mu    sync.RWMutex
+	items map[string]int
+}
+
+var errMissing = errors.New("missing")
+
+func (c *cache) earlyReturn(key string) (int, error) {
+	c.mu.RLock()
+	v, ok := c.items[key]
+	if !ok {
+		return 0, errMissing // want `found lock still held at return`
+	}
+	c.mu.RUnlock()
+	return v, nil
+}
+
+func (c *cache) releasedOnEveryPath(key string) (int, error) {
+	c.mu.RLock()
+	v, ok := c.items[key]
+	if !ok {
+		c.mu.RUnlock()
+		return 0, errMissing
+	}
+	c.mu.RUnlock()
+	return v, nil
+}
+
+func (c *cache) deferredRelease(key string) (int, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	v, ok := c.items[key]
+	if !ok {
+		return 0, errMissing
+	}
+	return v, nil
+}
+
+func (c *cache) deferredClosure(key string, v int) {
+	c.mu.Lock()
+	defer func() {
+		c.mu.Unlock()
+	}()
+	c.items[key] = v
+}
+
+func (c *cache) fallsOffTheEnd(key string, v int) {
+	c.mu.Lock()
+	c.items[key] = v
+} // want `found lock still held at return`
+
+// the deferred call releases the read lock, not the write lock
+func (c *cache) wrongDeferredRelease(key string, v int) {
+	c.mu.Lock()
+	defer c.mu.RUnlock()
+	c.items[key] = v
+} // want `found lock still held at return`
+
+func (c *cache) panics(key string) int {
+	c.mu.RLock()
+	v, ok := c.items[key]
+	if !ok {
+		panic(errMissing)
+	}
+	c.mu.RUnlock()
+	return v
+}
+
+func (c *cache) inClosure(keys []string) {
+	for _, key := range keys {
+		func() {
+			c.mu.Lock()
+			if key == "" {
+				return // want `found lock still held at return`
+			}
+			delete(c.items, key)
+			c.mu.Unlock()
+		}()
+	}
+}
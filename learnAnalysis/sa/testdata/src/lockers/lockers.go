@@ -0,0 +1,72 @@
+package lockers
+
+import "sync"
+
+type store struct {
+	mu   sync.RWMutex
+	data map[string]int
+}
+
+func (s *store) get(key string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.data[key]
+}
+
+func (s *store) set(key string, v int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = v
+}
+
+// readLocked reads s while holding l, which is expected to guard s
+func readLocked(l sync.Locker, s *store) int {
+	l.Lock()
+	defer l.Unlock()
+	return s.data["k"]
+}
+
+func lockerParam(s *store) {
+	s.mu.Lock()
+	readLocked(&s.mu, s) // want `found lock call while holding write lock`
+	s.mu.Unlock()
+}
+
+func rlockerParam(s *store) {
+	s.mu.RLock()
+	readLocked(s.mu.RLocker(), s) // want `found recursive read lock call`
+	s.mu.RUnlock()
+}
+
+func rlockerParamUnlocked(s *store) int {
+	return readLocked(s.mu.RLocker(), s)
+}
+
+func rlockerLocal(s *store) int {
+	l := s.mu.RLocker()
+	l.Lock()
+	defer l.Unlock()
+	return s.get("k") // want `found recursive read lock call`
+}
+
+func rlockerUpgrade(s *store) {
+	l := s.mu.RLocker()
+	l.Lock()
+	s.set("k", 1) // want `found write lock call while holding read lock`
+	l.Unlock()
+}
+
+func lockerLocal(s *store) {
+	var l sync.Locker = &s.mu
+	l.Lock()
+	s.mu.RLock() // want `found lock call while holding write lock`
+	s.mu.RUnlock()
+	l.Unlock()
+}
+
+func otherLocker(s, other *store) int {
+	var l sync.Locker = &other.mu
+	l.Lock()
+	defer l.Unlock()
+	return s.get("k")
+}
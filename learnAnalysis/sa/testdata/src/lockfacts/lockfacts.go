@@ -0,0 +1,95 @@
+package lockfacts
+
+import (
+	"iTypes"
+	"sync"
+)
+
+type ProtectResource struct {
+	*sync.RWMutex
+	resource string
+}
+
+func (r *ProtectResource) GetResource() string { // want GetResource:"acquires recv.RWMutex.RLock"
+	defer r.RUnlock()
+	r.RLock()
+	return r.resource
+}
+
+type NestedResource struct {
+	p ProtectResource
+}
+
+var mutex sync.RWMutex
+var resource = &ProtectResource{RWMutex: &sync.RWMutex{}}
+var nested = &NestedResource{p: ProtectResource{RWMutex: &sync.RWMutex{}}}
+var a = &iTypes.AwesomeProtectedResource{}
+
+func ViaHelper() { // want ViaHelper:"acquires lockfacts.mutex.RLock via lockfacts.regularRLock"
+	regularRLock()
+}
+
+func regularRLock() {
+	mutex.RLock()
+	mutex.RUnlock()
+}
+
+func Global() { // want Global:"acquires lockfacts.resource.RWMutex.RLock"
+	resource.RLock()
+	resource.RUnlock()
+}
+
+func Param(r *ProtectResource) { // want Param:"acquires param0.RWMutex.RLock"
+	r.RLock()
+	r.RUnlock()
+}
+
+func NestedField() { // want NestedField:"acquires lockfacts.nested.p.RWMutex.RLock"
+	nested.p.RLock()
+	nested.p.RUnlock()
+}
+
+func ImportedMethod() { // want ImportedMethod:"acquires lockfacts.a.RWMutex.RLock"
+	a.GetResource()
+}
+
+func ImportedGlobal() { // want ImportedGlobal:"acquires iTypes.Shared.RWMutex.RLock"
+	iTypes.ReadShared()
+}
+
+func ImportedParam(h *iTypes.Holder) { // want ImportedParam:"acquires param0.Res.RWMutex.RLock"
+	iTypes.ReadHolder(h)
+}
+
+func ImportedNestedField(h *iTypes.Holder) { // want ImportedNestedField:"acquires param0.Res.RWMutex.RLock"
+	h.Read()
+}
+
+func unexported() {
+	mutex.RLock()
+	mutex.RUnlock()
+}
+
+func Local() {
+	var mu sync.Mutex
+	mu.Lock()
+	mu.Unlock()
+}
+
+var shards [4]ProtectResource
+
+func Shard(i int) { // want Shard:"acquires lockfacts.shards.\\[param0\\].RWMutex.RLock"
+	shards[i].RLock()
+	shards[i].RUnlock()
+}
+
+func ConstantShard() { // want ConstantShard:"acquires lockfacts.shards.\\[2\\].RWMutex.RLock via Shard"
+	Shard(2)
+}
+
+func EveryShard() { // want EveryShard:"acquires lockfacts.shards.\\[\\?\\].RWMutex.RLock"
+	for i := range shards {
+		shards[i].RLock()
+		shards[i].RUnlock()
+	}
+}
@@ -0,0 +1,152 @@
+package lockorder // want package:"orders lockorder.A.mu -> lockorder.B.mu, .*lockorderdep.Ledger.Mu -> lockorderdep.Account.Mu"
+
+import (
+	"lockorderdep"
+	"sync"
+)
+
+type A struct {
+	mu sync.Mutex
+}
+
+type B struct {
+	mu sync.RWMutex
+}
+
+type C struct {
+	mu sync.Mutex
+}
+
+func lockAThenB(a *A, b *B) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	b.mu.RLock() // want `found lock order inversion: lockorder.B.mu acquired while holding lockorder.A.mu`
+	b.mu.RUnlock()
+}
+
+// the inversion with lockAThenB is only reported there
+func lockBThenA(a *A, b *B) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	lockA(a)
+}
+
+func lockA(a *A) {
+	a.mu.Lock()
+	a.mu.Unlock()
+}
+
+// A is always locked before C, so there is nothing to report
+func lockAThenC(a *A, c *C) {
+	a.mu.Lock()
+	c.mu.Lock()
+	c.mu.Unlock()
+	a.mu.Unlock()
+}
+
+func lockCAfterA(a *A, c *C) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}()
+}
+
+// C is released before A is acquired
+func lockCThenReleaseThenA(a *A, c *C) {
+	c.mu.Lock()
+	c.mu.Unlock()
+	lockA(a)
+}
+
+// the ledger is locked before the account in lockorderdep
+func auditAccount(a *lockorderdep.Account, l *lockorderdep.Ledger) {
+	a.Mu.Lock()
+	defer a.Mu.Unlock()
+	l.Mu.Lock() // want `found lock order inversion: lockorderdep.Ledger.Mu acquired while holding lockorderdep.Account.Mu`
+	l.Mu.Unlock()
+}
+
+// locking two accounts is a lock order problem of its own, but not an inversion between classes
+func transfer(from, to *lockorderdep.Account, amount int) {
+	from.Mu.Lock()
+	defer from.Mu.Unlock()
+	to.Mu.Lock()
+	defer to.Mu.Unlock()
+	from.Balance -= amount
+	to.Balance += amount
+}
+
+type D struct {
+	a A
+	b B
+}
+
+func (d *D) storeB() func() {
+	return func() {
+		d.b.mu.Lock()
+		d.b.mu.Unlock()
+	}
+}
+
+// the function literal storeB returns runs later, without d.a.mu held
+func (d *D) lockAThenStoreB() {
+	d.a.mu.Lock()
+	defer d.a.mu.Unlock()
+	_ = d.storeB()
+}
+
+var retry bool
+
+// lockBRetry and retryB call each other, so retryB may lock B whichever of them is summarized first
+func lockBRetry(b *B) {
+	b.mu.Lock()
+	b.mu.Unlock()
+	retryB(b)
+}
+
+func retryB(b *B) {
+	if retry {
+		lockBRetry(b)
+	}
+}
+
+type G struct {
+	mu sync.Mutex
+}
+
+func lockGThenRetryB(g *G, b *B) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	retryB(b) // want `found lock order inversion: lockorder.B.mu acquired while holding lockorder.G.mu`
+}
+
+func lockBThenG(b *B, g *G) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	g.mu.Lock()
+	g.mu.Unlock()
+}
+
+type E struct {
+	mu sync.Mutex
+}
+
+// lock returns with e.mu held for its caller
+func (e *E) lock() {
+	e.mu.Lock()
+}
+
+func lockEThenA(a *A, e *E) {
+	e.lock()
+	defer e.mu.Unlock()
+	lockA(a) // want `found lock order inversion: lockorder.A.mu acquired while holding lockorder.E.mu`
+}
+
+func lockAThenE(a *A, e *E) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	e.mu.Lock()
+	e.mu.Unlock()
+}
@@ -0,0 +1,23 @@
+package lockorderdep
+
+import "sync"
+
+type Account struct {
+	Mu      sync.Mutex
+	Balance int
+}
+
+type Ledger struct {
+	Mu      sync.Mutex
+	Entries []int
+}
+
+// Record locks the ledger before the account it records an amount for
+func (l *Ledger) Record(a *Account, amount int) {
+	l.Mu.Lock()
+	defer l.Mu.Unlock()
+	a.Mu.Lock()
+	a.Balance += amount
+	a.Mu.Unlock()
+	l.Entries = append(l.Entries, amount)
+}
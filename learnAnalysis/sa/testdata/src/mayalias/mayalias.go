@@ -0,0 +1,42 @@
+package mayalias
+
+import "sync"
+
+type shard struct {
+	mu   sync.Mutex
+	data map[string]int
+}
+
+type store struct {
+	shards []shard
+}
+
+func (s *store) differentIndices(i, j int) {
+	s.shards[i].mu.Lock()
+	s.shards[j].mu.Lock() // want `found lock call while holding write lock`
+	s.shards[j].mu.Unlock()
+	s.shards[i].mu.Unlock()
+}
+
+func (s *store) get(key string) int {
+	i := len(key) % len(s.shards)
+	s.shards[i].mu.Lock()
+	defer s.shards[i].mu.Unlock()
+	return s.shards[i].data[key]
+}
+
+func (s *store) throughCall(i int) int {
+	s.shards[i].mu.Lock()
+	defer s.shards[i].mu.Unlock()
+	return s.get("k") // want `found lock call while holding write lock`
+}
+
+// lockAll is still fine: the shard locked by an earlier iteration is forgotten once the index is assigned
+func (s *store) lockAll() {
+	for i := range s.shards {
+		s.shards[i].mu.Lock()
+	}
+	for i := range s.shards {
+		s.shards[i].mu.Unlock()
+	}
+}
@@ -0,0 +1,93 @@
+package methodvalues
+
+import "sync"
+
+type resource struct {
+	mu   sync.RWMutex
+	data string
+}
+
+func (r *resource) get() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.data
+}
+
+func boundToVariable(r *resource) {
+	r.mu.RLock()
+	get := r.get
+	get() // want `found recursive read lock call`
+	r.mu.RUnlock()
+}
+
+func boundToOtherReceiver(r, other *resource) {
+	r.mu.RLock()
+	get := other.get
+	get()
+	r.mu.RUnlock()
+}
+
+func methodExpression(r *resource) {
+	r.mu.RLock()
+	(*resource).get(r) // want `found recursive read lock call`
+	r.mu.RUnlock()
+}
+
+func methodExpressionBoundToVariable(r, other *resource) {
+	r.mu.Lock()
+	get := (*resource).get
+	get(other)
+	get(r) // want `found lock call while holding write lock`
+	r.mu.Unlock()
+}
+
+func run(f func() string) string {
+	return f()
+}
+
+func runLater(f func() string) func() string {
+	return f
+}
+
+func runThroughHelper(f func() string) string {
+	return run(f)
+}
+
+func callback(r *resource) {
+	r.mu.RLock()
+	run(r.get) // want `found recursive read lock call`
+	r.mu.RUnlock()
+}
+
+func callbackThroughHelper(r *resource) {
+	r.mu.Lock()
+	runThroughHelper(r.get) // want `found lock call while holding write lock`
+	r.mu.Unlock()
+}
+
+// runLater does not call f, so the lock is not acquired while it is held
+func callbackNotCalled(r *resource) {
+	r.mu.RLock()
+	runLater(r.get)
+	r.mu.RUnlock()
+}
+
+func do(f func()) {
+	f()
+}
+
+func lockMethodCallback(r *resource) {
+	r.mu.Lock()
+	do(r.mu.RLock) // want `found lock call while holding write lock`
+	r.mu.Unlock()
+}
+
+type outer struct {
+	resource
+}
+
+func promotedMethodValue(o *outer) {
+	o.resource.mu.RLock()
+	run(o.get) // want `found recursive read lock call`
+	o.resource.mu.RUnlock()
+}
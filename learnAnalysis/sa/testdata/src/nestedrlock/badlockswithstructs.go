@@ -1,4 +1,4 @@
-package testdata
+package nestedrlock
 
 var resource *ProtectResource = &ProtectResource{resource: "protected"}
 var nested *NestedResource = &NestedResource{p: ProtectResource{resource: "hello"}}
@@ -0,0 +1,91 @@
+package nestedrlock
+
+func readValue() int {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return 1
+}
+
+func earlyReturn(fail bool) {
+	mutex.RLock()
+	if fail {
+		mutex.RUnlock()
+		return
+	}
+	regularRLock() // want `found recursive read lock call`
+	mutex.RUnlock()
+}
+
+func unlockedOnEveryBranch(b bool) {
+	mutex.RLock()
+	if b {
+		mutex.RUnlock()
+	} else {
+		mutex.RUnlock()
+	}
+	regularRLock()
+}
+
+func lockedOnOneBranch(b bool) {
+	if b {
+		mutex.RLock()
+	}
+	regularRLock() // want `found recursive read lock call`
+	if b {
+		mutex.RUnlock()
+	}
+} // want `found lock still held at return`
+
+func lockedOnPreviousIteration(n int) {
+	for i := 0; i < n; i++ {
+		regularRLock() // want `found recursive read lock call`
+		mutex.RLock()  // want `found recursive read lock call`
+	}
+} // want `found lock still held at return`
+
+func unlockedInLoop(n int) {
+	for i := 0; i < n; i++ {
+		mutex.RLock()
+		mutex.RUnlock()
+		regularRLock()
+	}
+}
+
+func switchArms(n int) {
+	mutex.RLock()
+	switch n {
+	case 0:
+		mutex.RUnlock()
+		return
+	case 1:
+		regularRLock() // want `found recursive read lock call`
+	}
+	mutex.RUnlock()
+}
+
+func selectArms(c chan int, done chan bool) {
+	mutex.RLock()
+	select {
+	case <-done:
+		mutex.RUnlock()
+		return
+	case <-c:
+		callRegularRLock() // want `found recursive read lock call`
+	}
+	mutex.RUnlock()
+}
+
+func deferredUnlockAfterReturn() int {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return readValue() // want `found recursive read lock call`
+}
+
+func lockedInClosure() {
+	f := func() {
+		mutex.RLock()
+		regularRLock() // want `found recursive read lock call`
+		mutex.RUnlock()
+	}
+	f()
+}
@@ -0,0 +1,118 @@
+package outliers
+
+import "sync"
+
+type resource struct {
+	mu sync.RWMutex
+	x  int
+}
+
+func VarStmtCalled() {
+	r := &resource{}
+	var x func() = func() {
+		r.mu.RLock()
+		r.x += 1
+		r.mu.RUnlock()
+	}
+	r.mu.RLock()
+	x() // want `found recursive read lock call`
+	r.mu.RUnlock()
+}
+
+func AssignStmtCalled() {
+	r := &resource{}
+	x := func() {
+		r.mu.Lock()
+		r.x += 1
+		r.mu.Unlock()
+	}
+	r.mu.RLock()
+	x() // want `found write lock call while holding read lock`
+	r.mu.RUnlock()
+}
+
+func StructMethodCalled() {
+	r := &resource{}
+	s := &struct{ metho func() }{
+		metho: func() {
+			r.mu.RLock()
+			r.x += 1
+			r.mu.RUnlock()
+		},
+	}
+	r.mu.RLock()
+	s.metho() // want `found recursive read lock call`
+	r.mu.RUnlock()
+}
+
+func StructMethodCalledUnary() {
+	r := &resource{}
+	s := &struct{ metho func() }{
+		metho: func() {
+			r.mu.RLock()
+			r.x += 1
+			r.mu.RUnlock()
+		},
+	}
+	r.mu.Lock()
+	(*s).metho() // want `found lock call while holding write lock`
+	r.mu.Unlock()
+}
+
+func StructMethodPositional() {
+	r := &resource{}
+	s := struct {
+		name  string
+		metho func()
+	}{"positional", func() {
+		r.mu.RLock()
+		r.x += 1
+		r.mu.RUnlock()
+	}}
+	r.mu.RLock()
+	s.metho() // want `found recursive read lock call`
+	r.mu.RUnlock()
+}
+
+func StructMethodAssignedThenCalled() {
+	r := &resource{}
+	var s *struct{ metho func() }
+	s.metho = func() {
+		r.mu.RLock()
+		r.x += 1
+		r.mu.RUnlock()
+	}
+	r.mu.RLock()
+	s.metho() // want `found recursive read lock call`
+	r.mu.RUnlock()
+}
+
+// the field of another variable holds a different function
+func StructMethodOtherVariable() {
+	r := &resource{}
+	s := &struct{ metho func() }{
+		metho: func() {
+			r.mu.RLock()
+			r.x += 1
+			r.mu.RUnlock()
+		},
+	}
+	other := &struct{ metho func() }{
+		metho: func() {},
+	}
+	s.metho()
+	r.mu.RLock()
+	other.metho()
+	r.mu.RUnlock()
+}
+
+func FuncLitCalled() {
+	r := &resource{}
+	r.mu.RLock()
+	func() { // want `found recursive read lock call`
+		r.mu.RLock()
+		r.x += 1
+		r.mu.RUnlock()
+	}()
+	r.mu.RUnlock()
+}
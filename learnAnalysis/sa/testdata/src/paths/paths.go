@@ -0,0 +1,86 @@
+package paths
+
+import "sync"
+
+type resource struct {
+	mu   sync.RWMutex
+	data string
+}
+
+type holder struct {
+	res resource
+}
+
+func readResource(p *resource) string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.data
+}
+
+// the lock holder is passed as an argument
+func passedAsArgument(h *holder) {
+	h.res.mu.RLock()
+	readResource(&h.res) // want `found recursive read lock call`
+	h.res.mu.RUnlock()
+}
+
+func (h *holder) resource() *resource {
+	return &h.res
+}
+
+func pick(p *resource) *resource {
+	return p
+}
+
+func (h *holder) readThroughResult() string {
+	r := h.resource().data
+	h.resource().mu.RLock()
+	defer h.resource().mu.RUnlock()
+	return r
+}
+
+// the lock is selected from the result of a call returning a path rooted at the receiver
+func (h *holder) writeThenRead() {
+	h.res.mu.Lock()
+	h.readThroughResult() // want `found lock call while holding write lock`
+	h.res.mu.Unlock()
+}
+
+func readPicked(h *holder) {
+	pick(&h.res).mu.RLock()
+	pick(&h.res).mu.RUnlock()
+}
+
+// the result is rooted at a parameter of the called function
+func pickedAgain(h *holder) {
+	h.res.mu.RLock()
+	readPicked(h) // want `found recursive read lock call`
+	h.res.mu.RUnlock()
+}
+
+type inner struct {
+	mu sync.RWMutex
+}
+
+func (i *inner) read() {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+}
+
+type outer struct {
+	inner
+	other sync.Mutex
+}
+
+// the receiver of a promoted method is the embedded field it is declared on
+func (o *outer) upgrade() {
+	o.inner.mu.Lock()
+	o.read() // want `found lock call while holding write lock`
+	o.inner.mu.Unlock()
+}
+
+func (o *outer) otherLock() {
+	o.other.Lock()
+	o.read()
+	o.other.Unlock()
+}
@@ -0,0 +1,78 @@
+package relock
+
+import (
+	"iTypes"
+	"sync"
+)
+
+type counter struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (c *counter) inc() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.n++
+}
+
+type table struct {
+	sync.RWMutex
+	rows []string
+}
+
+func (t *table) len() int {
+	t.RLock()
+	defer t.RUnlock()
+	return len(t.rows)
+}
+
+func (t *table) add(row string) {
+	t.Lock()
+	defer t.Unlock()
+	t.rows = append(t.rows, row)
+}
+
+func lockTwice(c *counter) {
+	c.mu.Lock()
+	c.mu.Lock() // want `found lock call while holding write lock`
+	c.mu.Unlock()
+	c.mu.Unlock()
+}
+
+func lockThroughMethod(c *counter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inc() // want `found lock call while holding write lock`
+}
+
+func readUnderWrite(t *table) {
+	t.Lock()
+	defer t.Unlock()
+	if t.len() > 10 { // want `found lock call while holding write lock`
+		return
+	}
+}
+
+func writeUnderWrite(t *table) {
+	t.Lock()
+	t.add("row") // want `found lock call while holding write lock`
+	t.Unlock()
+}
+
+func acrossPackages(a *iTypes.AwesomeProtectedResource) {
+	a.Lock()
+	a.GetResource()    // want `found lock call while holding write lock`
+	a.SetResource("r") // want `found lock call while holding write lock`
+	a.Unlock()
+}
+
+func unlockedFirst(c *counter, t *table) {
+	c.mu.Lock()
+	c.mu.Unlock()
+	c.inc()
+	t.Lock()
+	t.Unlock()
+	t.add("row")
+	t.len()
+}
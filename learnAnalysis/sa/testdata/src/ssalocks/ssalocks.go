@@ -0,0 +1,134 @@
+package ssalocks
+
+import (
+	"sort"
+	"sync"
+)
+
+type shard struct {
+	mu    sync.RWMutex
+	items map[string]string
+}
+
+type store struct {
+	mu     sync.RWMutex
+	shards [4]*shard
+}
+
+func (s *store) getShard(k int) *shard {
+	return s.shards[k%len(s.shards)]
+}
+
+func (s *store) get(k int, key string) string {
+	sh := s.getShard(k)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	return sh.items[key]
+}
+
+func (s *store) read() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+}
+
+func shardFromCall(s *store, k int) {
+	s.getShard(k).mu.RLock()
+	s.getShard(k).mu.RLock() // want `found recursive read lock call`
+	s.getShard(k).mu.RUnlock()
+	s.getShard(k).mu.RUnlock()
+}
+
+func addressOfField(s *store) {
+	(&s.mu).RLock()
+	s.read() // want `found recursive read lock call`
+	(&s.mu).RUnlock()
+}
+
+func aliasedField(s *store) {
+	mu := &s.mu
+	mu.RLock()
+	s.read() // want `found recursive read lock call`
+	mu.RUnlock()
+	s.read()
+}
+
+func lockedThroughCapturedAlias(s *store) {
+	mu := &s.mu
+	s.mu.RLock()
+	func() {
+		mu.RLock()
+		mu.RUnlock()
+	}() // want `found recursive read lock call`
+	s.mu.RUnlock()
+}
+
+func differentShards(s *store) {
+	s.getShard(0).mu.RLock()
+	s.getShard(1).mu.RLock()
+	s.getShard(1).mu.RUnlock()
+	s.getShard(0).mu.RUnlock()
+}
+
+type reader interface {
+	read()
+}
+
+func readThroughInterface(s *store) {
+	var r reader = s
+	s.mu.RLock()
+	r.read() // want `found recursive read lock call`
+	s.mu.RUnlock()
+	r.read()
+}
+
+func sortedUnderLock(s *store, keys []string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sort.Slice(keys, func(i, j int) bool { // want `found recursive read lock call`
+		s.read()
+		return keys[i] < keys[j]
+	})
+}
+
+func storedUnderLock(s *store) func() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return func() {
+		s.read()
+	}
+}
+
+var retry bool
+
+// readRetry and retryRead call each other, so retryRead may read lock whichever of them is summarized first
+func readRetry(s *store) {
+	s.read()
+	retryRead(s)
+}
+
+func retryRead(s *store) {
+	if retry {
+		readRetry(s)
+	}
+}
+
+func retriedUnderLock(s *store) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	readRetry(s) // want `found recursive read lock call`
+	retryRead(s) // want `found recursive read lock call`
+}
+
+type node struct {
+	mu   sync.Mutex
+	next *node
+}
+
+// the summary of walk is bounded, although every call locks a node further down the list
+func walk(n *node) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.next != nil {
+		walk(n.next)
+	}
+}
@@ -0,0 +1,85 @@
+package trylock
+
+import "sync"
+
+type store struct {
+	mu   sync.RWMutex
+	data map[string]int
+}
+
+func (s *store) read(k string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.data[k]
+}
+
+func tryRead(s *store, k string) (int, bool) {
+	if s.mu.TryRLock() {
+		defer s.mu.RUnlock()
+		return s.read(k), true // want `found recursive read lock call`
+	}
+	return s.read(k), false
+}
+
+func tryWrite(s *store) {
+	if !s.mu.TryLock() {
+		s.read("k")
+		return
+	}
+	s.data["k"] = s.read("k") // want `found lock call while holding write lock`
+	s.mu.Unlock()
+}
+
+func tryResult(s *store) {
+	ok := s.mu.TryLock()
+	if ok {
+		s.mu.RLock() // want `found lock call while holding write lock`
+		s.mu.RUnlock()
+		s.mu.Unlock()
+	}
+}
+
+func tryHeld(s *store) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.mu.TryLock() { // want `found try lock call while holding the lock`
+		s.mu.Unlock()
+	}
+}
+
+func tryLeak(s *store) bool {
+	if s.mu.TryLock() {
+		return true // want `found lock still held at return`
+	}
+	return false
+}
+
+func spin(s *store) {
+	for !s.mu.TryLock() {
+	}
+	defer s.mu.Unlock()
+	s.read("k") // want `found lock call while holding write lock`
+}
+
+func tryOther(s, other *store) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if other.mu.TryRLock() {
+		other.mu.RUnlock()
+	}
+}
+
+var shared store
+
+func sharedMu() *sync.RWMutex {
+	return &shared.mu
+}
+
+// the mutex returned by a call has no access path, so the try-lock is not compared with the held lock
+func tryReturned(s *store) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sharedMu().TryLock() {
+		sharedMu().Unlock()
+	}
+}
@@ -0,0 +1,88 @@
+package unlocks
+
+import "sync"
+
+type store struct {
+	mu   sync.RWMutex
+	data map[string]string
+}
+
+func (s *store) doubleRUnlock(key string) string {
+	s.mu.RLock()
+	v := s.data[key]
+	s.mu.RUnlock()
+	s.mu.RUnlock() // want `found unlock of a lock that is not held`
+	return v
+}
+
+func (s *store) unlockedOnOnePath(key string) string {
+	s.mu.RLock()
+	v, ok := s.data[key]
+	if !ok {
+		s.mu.RUnlock()
+	}
+	s.mu.RUnlock() // want `found unlock of a lock that is not held`
+	return v
+}
+
+func (s *store) deferredAndExplicit(key string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v := s.data[key]
+	s.mu.RUnlock()
+	return v // want `found deferred unlock of a lock that is not held`
+}
+
+// the deferred call is only made on the path acquiring the lock again
+func (s *store) deferredOnRelock(key string, again bool) string {
+	s.mu.Lock()
+	v := s.data[key]
+	s.mu.Unlock()
+	if again {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		v = s.data[key]
+	}
+	return v
+}
+
+// the lock is acquired again before the deferred call releases it
+func (s *store) unlockedWhileWaiting(key string, wait func()) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mu.Unlock()
+	wait()
+	s.mu.Lock()
+	return s.data[key]
+}
+
+func (s *store) readLockWriteUnlock(key string) string {
+	s.mu.RLock()
+	v := s.data[key]
+	s.mu.Unlock() // want `found write unlock of a read lock`
+	return v
+}
+
+func (s *store) writeLockReadUnlock(key, v string) {
+	s.mu.Lock()
+	s.data[key] = v
+	s.mu.RUnlock() // want `found read unlock of a write lock`
+}
+
+// the caller holds the lock
+func (s *store) unlockForCaller() {
+	s.mu.Unlock()
+}
+
+func (s *store) unlockTwiceForCaller() {
+	s.mu.Unlock()
+	s.mu.Unlock() // want `found unlock of a lock that is not held`
+}
+
+func (s *store) relockInLoop(keys []string) {
+	for _, key := range keys {
+		s.mu.Lock()
+		delete(s.data, key)
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,60 @@
+package upgrade
+
+import (
+	"iTypes"
+	"sync"
+)
+
+var mu sync.RWMutex
+
+type cache struct {
+	sync.RWMutex
+	items map[string]string
+}
+
+func (c *cache) set(k, v string) {
+	c.Lock()
+	defer c.Unlock()
+	c.items[k] = v
+}
+
+func (c *cache) setThroughHelper(k, v string) {
+	c.set(k, v)
+}
+
+func direct() {
+	mu.RLock()
+	mu.Lock() // want `found write lock call while holding read lock`
+	mu.Unlock()
+	mu.RUnlock()
+}
+
+func throughMethod(c *cache) {
+	c.RLock()
+	c.set("k", "v") // want `found write lock call while holding read lock`
+	c.RUnlock()
+}
+
+func throughTwoMethods(c *cache) {
+	c.RLock()
+	defer c.RUnlock()
+	c.setThroughHelper("k", "v") // want `found write lock call while holding read lock`
+}
+
+func acrossPackages(a *iTypes.AwesomeProtectedResource) {
+	a.RLock()
+	a.SetResource("r") // want `found write lock call while holding read lock`
+	a.RUnlock()
+}
+
+func afterUnlock(c *cache) {
+	c.RLock()
+	c.RUnlock()
+	c.set("k", "v")
+}
+
+func differentMutex(c *cache) {
+	mu.RLock()
+	c.set("k", "v")
+	mu.RUnlock()
+}
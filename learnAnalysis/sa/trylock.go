@@ -0,0 +1,98 @@
+package sa
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/cfg"
+	"golang.org/x/tools/go/ssa"
+)
+
+// tryLockMethods maps each try-lock method to the lock method it acquires the lock with when it succeeds. Try-lock
+// calls never block, so they are not lock acquisitions of the functions making them, and the lock is only held on the
+// branch taken when they return true.
+var tryLockMethods = map[string]string{
+	"TryRLock": "RLock",
+	"TryLock":  "Lock",
+}
+
+// tryLockCond returns the try-lock call the condition cond tests, and whether it is negated, like in
+// if !mu.TryLock() { return }. A variable declared with the result of a try-lock call counts as the call itself (see
+// declaredValue).
+func tryLockCond(info *types.Info, cond ast.Expr) (call *ast.CallExpr, negated bool) {
+	for {
+		switch e := ast.Unparen(cond).(type) {
+		case *ast.UnaryExpr:
+			if e.Op != token.NOT {
+				return nil, false
+			}
+			cond, negated = e.X, !negated
+		case *ast.Ident: // ok := mu.TryLock(); if ok {...}
+			if _, isVar := info.ObjectOf(e).(*types.Var); !isVar {
+				return nil, false
+			}
+			if cond = declaredValue(e); cond == nil {
+				return nil, false
+			}
+		case *ast.CallExpr:
+			if c := getCallInfo(info, e); c == nil || tryLockMethods[c.id] == "" {
+				return nil, false
+			}
+			return e, negated
+		default:
+			return nil, false
+		}
+	}
+}
+
+// branch returns the state on the edge from b to its successor succ. The successors of a block ending in a condition
+// are taken when it is true and false, in that order, so a try-lock call tested by the condition acquires its lock
+// on one of them.
+func (f *lockFlow) branch(b *cfg.Block, succ int, state lockState) lockState {
+	if len(b.Succs) != 2 || len(b.Nodes) == 0 {
+		return state
+	}
+	cond, ok := b.Nodes[len(b.Nodes)-1].(ast.Expr)
+	if !ok {
+		return state
+	}
+	call, negated := tryLockCond(f.pass.TypesInfo, cond)
+	if call == nil || (succ == 0) == negated {
+		return state
+	}
+	lock, ok := f.summaries.callPath(call)
+	if !ok {
+		return state
+	}
+	return state.acquire(lock.withMethod(tryLockMethods[lock.method()]))
+}
+
+// branch returns the state on the edge from b to its successor succ, acquiring the lock of a try-lock call tested by
+// the If instruction ending b on the edge taken when it succeeds
+func (f *ssaLockFlow) branch(b *ssa.BasicBlock, succ int, state ssaLockState) ssaLockState {
+	if len(b.Instrs) == 0 {
+		return state
+	}
+	ifInstr, ok := b.Instrs[len(b.Instrs)-1].(*ssa.If)
+	if !ok {
+		return state
+	}
+	cond, negated := ifInstr.Cond, false
+	for {
+		not, ok := cond.(*ssa.UnOp)
+		if !ok || not.Op != token.NOT {
+			break
+		}
+		cond, negated = not.X, !negated
+	}
+	call, ok := cond.(*ssa.Call)
+	if !ok || (succ == 0) == negated {
+		return state
+	}
+	method, lock, ok := lockCall(call.Common())
+	if !ok || tryLockMethods[method] == "" {
+		return state
+	}
+	return f.acquire(state, lock, tryLockMethods[method], call.Pos())
+}